@@ -0,0 +1,174 @@
+package webresource
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fingerprintLen is the number of hex digits of the content hash used in fingerprinted URLs
+const fingerprintLen = 16
+
+// immutableCacheControl is sent for fingerprinted URLs, the content at such a URL never changes
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// NewAssets returns an empty Assets which will serve files under prefix (e.g. "/assets/").
+func NewAssets(prefix string) *Assets {
+	return &Assets{
+		prefix: "/" + strings.Trim(prefix, "/") + "/",
+		byName: make(map[string]*Asset),
+		byURL:  make(map[string]*Asset),
+	}
+}
+
+// Assets is a set of files served at content-hash fingerprinted URLs with immutable caching,
+// e.g. logical name "combined.js" is served at "/assets/combined.0123456789abcdef.js".
+// Assets is safe for concurrent use.
+type Assets struct {
+	prefix string
+	mu     sync.RWMutex
+	byName map[string]*Asset
+	byURL  map[string]*Asset
+}
+
+// Asset is a single fingerprinted file.
+type Asset struct {
+	Name    string    // logical name, e.g. "combined.js" or "github.com/x/jquery/jquery.js"
+	URL     string    // fingerprinted URL, e.g. "/assets/combined.0123456789abcdef.js"
+	Hash    string    // hex encoded SHA-256 of Content
+	ModTime time.Time // modification time, used for Last-Modified
+	Content []byte
+}
+
+// Integrity returns the Subresource Integrity value for the Asset's content, see Integrity().
+func (asset *Asset) Integrity(alg string) (string, error) {
+	return Integrity(alg, asset.Content)
+}
+
+// FingerprintName inserts the first digits of hash before the extension of name,
+// "a/combined.js" -> "a/combined.0123456789abcdef.js".
+func FingerprintName(name string, hash string) string {
+	if len(hash) > fingerprintLen {
+		hash = hash[:fingerprintLen]
+	}
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Add fingerprints content and registers it under the logical name given, replacing any
+// previous Asset with that name.
+func (a *Assets) Add(name string, modTime time.Time, content []byte) *Asset {
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	asset := &Asset{
+		Name:    name,
+		URL:     a.prefix + FingerprintName(name, hash),
+		Hash:    hash,
+		ModTime: modTime,
+		Content: content,
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if old := a.byName[name]; old != nil {
+		delete(a.byURL, old.URL)
+	}
+	a.byName[name] = asset
+	a.byURL[asset.URL] = asset
+
+	return asset
+}
+
+// AddModuleList adds each file with one of the extensions given from each Module in ml.
+// The logical name of each is the Module's Name() followed by the file's full path,
+// e.g. "github.com/x/jquery/jquery.js".
+func (a *Assets) AddModuleList(ml ModuleList, exts ...string) error {
+	for _, ext := range exts {
+		err := ml.Walk(ext, func(m Module, fullPath string, f http.File) error {
+			b, err := ioutil.ReadAll(f)
+			if err != nil {
+				return err
+			}
+			st, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			a.Add(m.Name()+fullPath, st.ModTime(), b)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Named returns the Asset with the logical name given, nil if not found.
+func (a *Assets) Named(name string) *Asset {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.byName[strings.TrimPrefix(path.Clean("/"+name), "/")]
+}
+
+// Names returns the logical names of all Assets, sorted.
+func (a *Assets) Names() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	ret := make([]string, 0, len(a.byName))
+	for name := range a.byName {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// URL returns the fingerprinted URL for a logical name, or an error if there is no such Asset.
+func (a *Assets) URL(name string) (string, error) {
+	asset := a.Named(name)
+	if asset == nil {
+		return "", fmt.Errorf("no asset named %q", name)
+	}
+	return asset.URL, nil
+}
+
+// FuncMap returns template functions for looking up fingerprinted URLs,
+// e.g. {{assetURL "combined.js"}}.
+func (a *Assets) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"assetURL": a.URL,
+	}
+}
+
+// ServeHTTP implements http.Handler and serves each Asset at its fingerprinted URL
+// with immutable caching.  Anything else is a 404.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	a.mu.RLock()
+	asset := a.byURL[r.URL.Path]
+	a.mu.RUnlock()
+
+	if asset == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", immutableCacheControl)
+	w.Header().Set("ETag", `"`+asset.Hash+`"`)
+	if ct := ContentType(asset.Name); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	setFontHeaders(w, asset.Name, "")
+	http.ServeContent(w, r, asset.Name, asset.ModTime, bytes.NewReader(asset.Content))
+}
@@ -0,0 +1,83 @@
+package webresource
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssets(t *testing.T) {
+
+	modTime := time.Unix(1500000000, 0)
+
+	a := NewFileSet("example.com/a").
+		Mkdir("/dist", 0755).
+		WriteFile("/dist/a.js", 0644, modTime, []byte(`/* a.js */`))
+
+	assets := NewAssets("/assets")
+	combined := assets.Add("combined.js", modTime, []byte(`/* combined */`))
+	err := assets.AddModuleList(ModuleList{a}, ".js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if combined.URL != "/assets/combined."+combined.Hash[:16]+".js" {
+		t.Fatalf("unexpected URL: %s", combined.URL)
+	}
+
+	aURL, err := assets.URL("example.com/a/dist/a.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(aURL, "/assets/example.com/a/dist/a.") || !strings.HasSuffix(aURL, ".js") {
+		t.Fatalf("unexpected URL: %s", aURL)
+	}
+
+	if _, err := assets.URL("missing.js"); err == nil {
+		t.Fatalf("expected error for missing asset")
+	}
+
+	// template lookup
+	tmpl := template.Must(template.New("t").Funcs(assets.FuncMap()).Parse(`{{assetURL "combined.js"}}`))
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != combined.URL {
+		t.Fatalf("unexpected template output: %s", buf.String())
+	}
+
+	// serving
+	w := httptest.NewRecorder()
+	assets.ServeHTTP(w, httptest.NewRequest("GET", combined.URL, nil))
+	if w.Code != http.StatusOK || w.Body.String() != `/* combined */` {
+		t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != immutableCacheControl {
+		t.Fatalf("unexpected Cache-Control: %s", w.Header().Get("Cache-Control"))
+	}
+
+	// the unfingerprinted name is not served
+	w = httptest.NewRecorder()
+	assets.ServeHTTP(w, httptest.NewRequest("GET", "/assets/combined.js", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+
+	// replacing content changes the URL
+	combined2 := assets.Add("combined.js", modTime, []byte(`/* combined v2 */`))
+	if combined2.URL == combined.URL {
+		t.Fatalf("URL did not change with content")
+	}
+	w = httptest.NewRecorder()
+	assets.ServeHTTP(w, httptest.NewRequest("GET", combined.URL, nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for old URL, got %d", w.Code)
+	}
+
+}
@@ -0,0 +1,275 @@
+// Concatenation of the files in a resolved ModuleList into one bundle per file type.
+package bundle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+// Transformer is applied to the concatenated content of a bundle, e.g. to minify it.
+type Transformer interface {
+	Transform(content []byte) ([]byte, error)
+}
+
+// TransformerFunc adapts a function to the Transformer interface.
+type TransformerFunc func(content []byte) ([]byte, error)
+
+func (f TransformerFunc) Transform(content []byte) ([]byte, error) { return f(content) }
+
+// FileTransformer is applied to each input file before concatenation, with the Module
+// and full path it comes from, e.g. to rewrite references relative to the file.
+// Source maps expect file transformers not to change line structure.
+type FileTransformer interface {
+	TransformFile(m webresource.Module, fullPath string, content []byte) ([]byte, error)
+}
+
+// FileTransformerFunc adapts a function to the FileTransformer interface.
+type FileTransformerFunc func(m webresource.Module, fullPath string, content []byte) ([]byte, error)
+
+func (f FileTransformerFunc) TransformFile(m webresource.Module, fullPath string, content []byte) ([]byte, error) {
+	return f(m, fullPath, content)
+}
+
+// File describes one input file of a Bundle.
+type File struct {
+	Module   webresource.Module
+	FullPath string
+	ModTime  time.Time
+}
+
+// Bundle is the result of concatenating all files of one type.
+type Bundle struct {
+	Ext       string    // file extension this bundle was built from, e.g. ".js"
+	Content   []byte    // concatenated and transformed content
+	ModTime   time.Time // most recent ModTime of the input files
+	Hash      string    // hex encoded SHA-256 of Content
+	Files     []File    // input files in the sequence they were concatenated
+	SourceMap []byte    // JSON source map, nil unless enabled with SetSourceMap
+}
+
+// Integrity returns the Subresource Integrity value for the bundle's content, see webresource.Integrity().
+func (b *Bundle) Integrity(alg string) (string, error) {
+	return webresource.Integrity(alg, b.Content)
+}
+
+// NewBundler returns a Bundler for the files in ml with the extensions given.
+// The ModuleList is expected to already be Resolve()d, its sequence is used as-is.
+func NewBundler(ml webresource.ModuleList, exts ...string) *Bundler {
+	return &Bundler{
+		modules:          ml,
+		exts:             exts,
+		transformers:     make(map[string][]Transformer),
+		fileTransformers: make(map[string][]FileTransformer),
+		cache:            make(map[string]cacheEntry),
+	}
+}
+
+// Bundler concatenates the files of a ModuleList into a Bundle for each extension.
+type Bundler struct {
+	modules          webresource.ModuleList
+	exts             []string
+	transformers     map[string][]Transformer
+	fileTransformers map[string][]FileTransformer
+	sourceMapMode    SourceMapMode
+	sourceMapURL     func(ext string) string
+	inlineImports    bool
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry // file contents keyed by module name + full path
+}
+
+// cacheEntry holds the contents of an input file until its modTime or size changes,
+// so rebuilding after a change only reads the files that changed
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	content []byte
+}
+
+// readFile returns the contents of f, from the cache if it has not changed since last read
+func (b *Bundler) readFile(m webresource.Module, fullPath string, f http.File, st os.FileInfo) ([]byte, error) {
+	key := m.Name() + fullPath
+	b.mu.Lock()
+	e, ok := b.cache[key]
+	b.mu.Unlock()
+	if ok && e.modTime.Equal(st.ModTime()) && e.size == st.Size() {
+		return e.content, nil
+	}
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.cache[key] = cacheEntry{modTime: st.ModTime(), size: st.Size(), content: content}
+	b.mu.Unlock()
+	return content, nil
+}
+
+// AddTransformer adds a Transformer to be applied to bundles for the extension given.
+// Transformers are applied in the sequence they are added.
+func (b *Bundler) AddTransformer(ext string, t Transformer) *Bundler {
+	b.transformers[ext] = append(b.transformers[ext], t)
+	return b
+}
+
+// AddFileTransformer adds a FileTransformer to be applied to each input file with the extension given.
+// File transformers are applied in the sequence they are added, before the Transformers for the bundle.
+func (b *Bundler) AddFileTransformer(ext string, t FileTransformer) *Bundler {
+	b.fileTransformers[ext] = append(b.fileTransformers[ext], t)
+	return b
+}
+
+// SetSourceMap enables source maps which map each line of a bundle back to the module
+// name and full path it came from, composing in any map a module ships for its own files.
+// For SourceMapExternal, mapURL returns the URL the map will be served at for a bundle extension;
+// it is ignored for other modes.  The map describes the bundle before transformers are applied,
+// so transformers used together with source maps must not change line structure.
+func (b *Bundler) SetSourceMap(mode SourceMapMode, mapURL func(ext string) string) *Bundler {
+	b.sourceMapMode = mode
+	b.sourceMapURL = mapURL
+	return b
+}
+
+// SetInlineCSSImports enables inlining of relative CSS @import statements: each is resolved
+// against the importing file's Module and the imported content is put in its place (keeping
+// any layer, supports() and media conditions as the equivalent block rules).  An imported
+// file is then not also included on its own, its content goes through the file transformers
+// separately, and import cycles are an error.  By default @import statements are left as they are.
+func (b *Bundler) SetInlineCSSImports(inline bool) *Bundler {
+	b.inlineImports = inline
+	return b
+}
+
+// Build produces a Bundle for each extension, keyed by extension.
+func (b *Bundler) Build() (map[string]*Bundle, error) {
+	ret := make(map[string]*Bundle, len(b.exts))
+	for _, ext := range b.exts {
+		bundle, err := b.BuildExt(ext)
+		if err != nil {
+			return nil, err
+		}
+		ret[ext] = bundle
+	}
+	return ret, nil
+}
+
+// BuildExt produces the Bundle for a single extension.  Input file contents are cached
+// and only read again when their ModTime or size changes.  For ".css", @import statements
+// are inlined if enabled with SetInlineCSSImports.
+func (b *Bundler) BuildExt(ext string) (*Bundle, error) {
+
+	ret := &Bundle{Ext: ext}
+
+	var smb *sourceMapBuilder
+	if b.sourceMapMode != SourceMapNone {
+		smb = newSourceMapBuilder("")
+	}
+
+	inlineImports := ext == ".css" && b.inlineImports
+	var imported map[string]bool
+	if inlineImports {
+		var err error
+		imported, err = b.cssImported(ext)
+		if err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool)
+
+	var buf bytes.Buffer
+	line := 0
+	err := b.modules.Walk(ext, func(m webresource.Module, fullPath string, f http.File) error {
+		if imported[m.Name()+fullPath] {
+			return nil // included where it is imported
+		}
+		st, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		fb, err := b.readFile(m, fullPath, f, st)
+		if err != nil {
+			return err
+		}
+
+		pieces := []cssPiece{{m: m, fullPath: fullPath, modTime: st.ModTime(), content: fb}}
+		if inlineImports {
+			pieces, err = b.inlineCSSImports(m, fullPath, st.ModTime(), fb, nil, nil, seen, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, p := range pieces {
+			if p.modTime.After(ret.ModTime) {
+				ret.ModTime = p.modTime
+			}
+			ret.Files = append(ret.Files, File{Module: p.m, FullPath: p.fullPath, ModTime: p.modTime})
+			fb := p.content
+			for _, ft := range b.fileTransformers[ext] {
+				fb, err = ft.TransformFile(p.m, p.fullPath, fb)
+				if err != nil {
+					return fmt.Errorf("error transforming %s%s: %v", p.m.Name(), p.fullPath, err)
+				}
+			}
+			for _, w := range p.wrappers {
+				fmt.Fprintf(&buf, "%s {\n", w)
+				line++
+			}
+			if smb != nil {
+				fb, err = smb.addFile(p.m, p.fullPath, fb, line)
+				if err != nil {
+					return err
+				}
+			}
+			line += bytes.Count(fb, []byte("\n")) + 1
+			fmt.Fprintf(&buf, "%s\n", fb)
+			for range p.wrappers {
+				buf.WriteString("}\n")
+				line++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content := buf.Bytes()
+	for _, t := range b.transformers[ext] {
+		content, err = t.Transform(content)
+		if err != nil {
+			return nil, fmt.Errorf("error transforming %q bundle: %v", ext, err)
+		}
+	}
+
+	if smb != nil {
+		ret.SourceMap, err = smb.build()
+		if err != nil {
+			return nil, err
+		}
+		switch b.sourceMapMode {
+		case SourceMapInline:
+			content = append(content, sourceMappingComment(ext, inlineMapURL(ret.SourceMap))...)
+		case SourceMapExternal:
+			if b.sourceMapURL == nil {
+				return nil, fmt.Errorf("no source map URL func provided for external source maps")
+			}
+			content = append(content, sourceMappingComment(ext, b.sourceMapURL(ext))...)
+		}
+	}
+
+	ret.Content = content
+	sum := sha256.Sum256(content)
+	ret.Hash = hex.EncodeToString(sum[:])
+
+	return ret, nil
+}
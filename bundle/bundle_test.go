@@ -0,0 +1,55 @@
+package bundle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+func TestBundler(t *testing.T) {
+
+	t1 := time.Unix(1500000000, 0)
+	t2 := time.Unix(1600000000, 0)
+
+	a := webresource.NewFileSet("a").
+		WriteFile("/a.js", 0644, t1, []byte(`/* a.js */`)).
+		WriteFile("/a.css", 0644, t2, []byte(`/* a.css */`))
+	b := webresource.NewFileSet("b", a).
+		WriteFile("/b.js", 0644, t2, []byte(`/* b.js */`))
+
+	ml := webresource.Resolve(webresource.ModuleList{b})
+
+	bundles, err := NewBundler(ml, ".js", ".css").
+		AddTransformer(".js", TransformerFunc(func(content []byte) ([]byte, error) {
+			return bytes.ToUpper(content), nil
+		})).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js := bundles[".js"]
+	if string(js.Content) != "/* A.JS */\n/* B.JS */\n" {
+		t.Fatalf("unexpected js content: %q", js.Content)
+	}
+	if !js.ModTime.Equal(t2) {
+		t.Fatalf("unexpected js mod time: %v", js.ModTime)
+	}
+	if len(js.Files) != 2 || js.Files[0].Module.Name() != "a" || js.Files[1].FullPath != "/b.js" {
+		t.Fatalf("unexpected js files: %+v", js.Files)
+	}
+	sum := sha256.Sum256(js.Content)
+	if js.Hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected js hash: %s", js.Hash)
+	}
+
+	css := bundles[".css"]
+	if string(css.Content) != "/* a.css */\n" {
+		t.Fatalf("unexpected css content: %q", css.Content)
+	}
+
+}
@@ -0,0 +1,337 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+// cssImport is one relative @import statement at the start of a CSS file
+type cssImport struct {
+	start, end int      // byte range of the statement including the ";"
+	ref        string   // the imported reference as written
+	wrappers   []string // block rules the imported content must be wrapped in, e.g. "@media print"
+}
+
+// cssPiece is one file's contribution to a CSS bundle after @import inlining
+type cssPiece struct {
+	m        webresource.Module
+	fullPath string
+	modTime  time.Time
+	content  []byte
+	wrappers []string
+}
+
+// parseCSSImports returns the relative @import statements at the start of content,
+// where CSS allows them (after any @charset, before any other rule).  Imports of
+// absolute URLs are skipped and left for the browser.
+func parseCSSImports(content []byte) ([]cssImport, error) {
+
+	var ret []cssImport
+	s := string(content)
+	i := 0
+	for {
+		i = skipCSSSpace(s, i)
+		if hasPrefixFold(s[i:], "@charset") {
+			end := strings.IndexByte(s[i:], ';')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated @charset")
+			}
+			i += end + 1
+			continue
+		}
+		if !hasPrefixFold(s[i:], "@import") {
+			return ret, nil
+		}
+
+		start := i
+		end := cssStatementEnd(s, i)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated @import at offset %d", start)
+		}
+		stmt := strings.TrimSpace(s[start+len("@import") : end])
+		i = end + 1
+
+		ref, cond, err := splitCSSImport(stmt)
+		if err != nil {
+			return nil, err
+		}
+		refPath, _ := splitCSSRef(ref)
+		if !isRelativeCSSRef(refPath) {
+			continue
+		}
+		wrappers, err := cssImportWrappers(cond)
+		if err != nil {
+			return nil, fmt.Errorf("@import %q: %v", ref, err)
+		}
+		ret = append(ret, cssImport{start: start, end: i, ref: refPath, wrappers: wrappers})
+	}
+}
+
+// skipCSSSpace returns the offset of the first byte at or after i which is not whitespace or in a comment
+func skipCSSSpace(s string, i int) int {
+	for i < len(s) {
+		switch {
+		case s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r' || s[i] == '\f':
+			i++
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return len(s)
+			}
+			i += 2 + end + 2
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// cssStatementEnd returns the offset of the ";" ending the statement starting at i, skipping
+// over strings and parentheses, or -1
+func cssStatementEnd(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return -1
+			}
+			i += 1 + end
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ';':
+			if depth <= 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitCSSImport splits the part of an @import statement after the keyword into the
+// reference and the conditions which follow it
+func splitCSSImport(stmt string) (ref, cond string, err error) {
+	switch {
+	case strings.HasPrefix(stmt, `"`) || strings.HasPrefix(stmt, `'`):
+		end := strings.IndexByte(stmt[1:], stmt[0])
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string in @import %s", stmt)
+		}
+		return stmt[1 : 1+end], strings.TrimSpace(stmt[2+end:]), nil
+	case hasPrefixFold(stmt, "url("):
+		end := strings.IndexByte(stmt, ')')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated url() in @import %s", stmt)
+		}
+		ref = strings.TrimSpace(stmt[len("url("):end])
+		ref = strings.Trim(ref, `"'`)
+		return ref, strings.TrimSpace(stmt[end+1:]), nil
+	}
+	return "", "", fmt.Errorf("invalid @import %s", stmt)
+}
+
+// cssImportWrappers converts @import conditions, "layer(x) supports(display: grid) screen",
+// into the block rules which have the same effect on inlined content, outermost first
+func cssImportWrappers(cond string) ([]string, error) {
+
+	var ret []string
+
+	if hasPrefixFold(cond, "layer(") {
+		end := strings.IndexByte(cond, ')')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated layer()")
+		}
+		ret = append(ret, "@layer "+strings.TrimSpace(cond[len("layer("):end]))
+		cond = strings.TrimSpace(cond[end+1:])
+	} else if strings.EqualFold(cond, "layer") || hasPrefixFold(cond, "layer ") {
+		ret = append(ret, "@layer")
+		cond = strings.TrimSpace(cond[len("layer"):])
+	}
+
+	if hasPrefixFold(cond, "supports(") {
+		end := cssParenEnd(cond, len("supports"))
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated supports()")
+		}
+		inner := strings.TrimSpace(cond[len("supports("):end])
+		if !strings.HasPrefix(inner, "(") && !hasPrefixFold(inner, "not ") && !hasPrefixFold(inner, "selector(") {
+			inner = "(" + inner + ")" // a bare declaration
+		}
+		ret = append(ret, "@supports "+inner)
+		cond = strings.TrimSpace(cond[end+1:])
+	}
+
+	if cond != "" {
+		ret = append(ret, "@media "+cond)
+	}
+
+	return ret, nil
+}
+
+// cssParenEnd returns the offset of the ")" matching the "(" at i, or -1
+func cssParenEnd(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// cssImported returns the module name + full path of each CSS file in the bundle which
+// is @imported by another, these are included where they are imported rather than on their own.
+// Import cycles are an error, including those no other file leads into.
+func (b *Bundler) cssImported(ext string) (map[string]bool, error) {
+
+	edges := make(map[string][]string)
+	var keys []string
+	err := b.modules.Walk(ext, func(m webresource.Module, fullPath string, f http.File) error {
+		st, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		content, err := b.readFile(m, fullPath, f, st)
+		if err != nil {
+			return err
+		}
+		imports, err := parseCSSImports(content)
+		if err != nil {
+			return fmt.Errorf("error parsing %s%s: %v", m.Name(), fullPath, err)
+		}
+		key := m.Name() + fullPath
+		keys = append(keys, key)
+		for _, imp := range imports {
+			edges[key] = append(edges[key], m.Name()+path.Join(path.Dir(fullPath), imp.ref))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]bool)
+	for _, targets := range edges {
+		for _, t := range targets {
+			ret[t] = true
+		}
+	}
+
+	// depth first search, a file reached again while still on the stack is a cycle
+	done := make(map[string]bool)
+	var visit func(key string, stack []string) error
+	visit = func(key string, stack []string) error {
+		for i, s := range stack {
+			if s == key {
+				return fmt.Errorf("@import cycle: %s", strings.Join(append(stack[i:], key), " -> "))
+			}
+		}
+		if done[key] {
+			return nil
+		}
+		stack = append(stack, key)
+		for _, t := range edges[key] {
+			if err := visit(t, stack); err != nil {
+				return err
+			}
+		}
+		done[key] = true
+		return nil
+	}
+	for _, key := range keys {
+		if err := visit(key, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// inlineCSSImports appends the pieces for the file at fullPath to out: first those of
+// each file it @imports, recursively and in order, then its own content with the
+// @import statements blanked out (keeping line structure).  Since @import must precede
+// all other rules this keeps the cascade the same.  stack is the chain of files being
+// imported, guarding against cycles, and seen avoids including a file twice under the
+// same conditions.
+func (b *Bundler) inlineCSSImports(m webresource.Module, fullPath string, modTime time.Time, content []byte,
+	wrappers []string, stack []string, seen map[string]bool, out []cssPiece) ([]cssPiece, error) {
+
+	key := m.Name() + fullPath
+	for i, s := range stack {
+		if s == key {
+			return nil, fmt.Errorf("@import cycle: %s", strings.Join(append(stack[i:], key), " -> "))
+		}
+	}
+	stack = append(stack, key)
+
+	imports, err := parseCSSImports(content)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", key, err)
+	}
+
+	for _, imp := range imports {
+
+		target := path.Join(path.Dir(fullPath), imp.ref)
+		impWrappers := append(append([]string(nil), wrappers...), imp.wrappers...)
+
+		f, err := m.Open(target)
+		if err != nil {
+			return nil, fmt.Errorf("@import %q in %s: not found in module", imp.ref, key)
+		}
+		st, err := f.Stat()
+		if err == nil && st.IsDir() {
+			err = fmt.Errorf("is a directory")
+		}
+		var impContent []byte
+		if err == nil {
+			impContent, err = b.readFile(m, target, f, st)
+		}
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("@import %q in %s: %v", imp.ref, key, err)
+		}
+
+		seenKey := m.Name() + target + "\x00" + strings.Join(impWrappers, "\x00")
+		if seen[seenKey] {
+			continue
+		}
+		seen[seenKey] = true
+
+		out, err = b.inlineCSSImports(m, target, st.ModTime(), impContent, impWrappers, stack, seen, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(imports) > 0 {
+		blanked := make([]byte, 0, len(content))
+		last := 0
+		for _, imp := range imports {
+			blanked = append(blanked, content[last:imp.start]...)
+			blanked = append(blanked, bytes.Repeat([]byte("\n"), bytes.Count(content[imp.start:imp.end], []byte("\n")))...)
+			last = imp.end
+		}
+		content = append(blanked, content[last:]...)
+	}
+
+	return append(out, cssPiece{m: m, fullPath: fullPath, modTime: modTime, content: content, wrappers: wrappers}), nil
+}
@@ -0,0 +1,100 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+func TestCSSImports(t *testing.T) {
+
+	now := time.Now()
+	m := webresource.NewFileSet("example.com/theme").
+		Mkdir("/base", 0755).
+		WriteFile("/main.css", 0644, now, []byte(`@charset "utf-8";
+/* theme */
+@import "base/variables.css";
+@import url('print.css') print;
+@import url(https://fonts.example.com/css?family=X);
+body { color: var(--fg); }
+`)).
+		WriteFile("/base/variables.css", 0644, now, []byte(`@import "reset.css" layer(reset) supports(display: grid) screen and (min-width: 1px);
+:root { --fg: #111; }
+`)).
+		WriteFile("/base/reset.css", 0644, now, []byte("* { margin: 0; }\n")).
+		WriteFile("/print.css", 0644, now, []byte("nav { display: none; }\n"))
+
+	b, err := NewBundler(webresource.ModuleList{m}, ".css").SetInlineCSSImports(true).BuildExt(".css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `@layer reset {
+@supports (display: grid) {
+@media screen and (min-width: 1px) {
+* { margin: 0; }
+
+}
+}
+}
+
+:root { --fg: #111; }
+
+@media print {
+nav { display: none; }
+
+}
+@charset "utf-8";
+/* theme */
+
+
+@import url(https://fonts.example.com/css?family=X);
+body { color: var(--fg); }
+
+`
+	if string(b.Content) != expected {
+		t.Fatalf("unexpected output:\n%s", b.Content)
+	}
+
+	var files []string
+	for _, f := range b.Files {
+		files = append(files, f.FullPath)
+	}
+	if strings.Join(files, ",") != "/base/reset.css,/base/variables.css,/print.css,/main.css" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	// by default @import is left alone and every file is included
+	b, err = NewBundler(webresource.ModuleList{m}, ".css").BuildExt(".css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = nil
+	for _, f := range b.Files {
+		files = append(files, f.FullPath)
+	}
+	if !strings.Contains(string(b.Content), "@import \"base/variables.css\";\n@import url('print.css') print;") ||
+		strings.Join(files, ",") != "/base/variables.css,/base/reset.css,/main.css,/print.css" {
+		t.Fatalf("unexpected output without inlining: %v\n%s", files, b.Content)
+	}
+
+	// cycles are reported
+	m = webresource.NewFileSet("example.com/cycle").
+		WriteFile("/a.css", 0644, now, []byte(`@import "b.css";`)).
+		WriteFile("/b.css", 0644, now, []byte(`@import "c.css";`)).
+		WriteFile("/c.css", 0644, now, []byte(`@import "a.css";`))
+	_, err = NewBundler(webresource.ModuleList{m}, ".css").SetInlineCSSImports(true).BuildExt(".css")
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got: %v", err)
+	}
+
+	// missing imports are reported
+	m = webresource.NewFileSet("example.com/missing").
+		WriteFile("/a.css", 0644, now, []byte(`@import "nope.css";`))
+	_, err = NewBundler(webresource.ModuleList{m}, ".css").SetInlineCSSImports(true).BuildExt(".css")
+	if err == nil || !strings.Contains(err.Error(), `"nope.css"`) {
+		t.Fatalf("expected missing import error, got: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gocaveman/webresource"
+)
+
+// matches url(...) with optional quotes, submatches are the quote and the reference
+var cssURLRE = regexp.MustCompile(`url\(\s*(['"]?)([^'")]*)(['"]?)\s*\)`)
+
+// CSSURLRewriter is a FileTransformer for CSS which resolves relative url() references
+// against the Module the CSS file is in and rewrites them to the URL the referenced file
+// is served at (e.g. fingerprinted by a ModuleHandler), so they keep working after
+// concatenation.  References with a scheme, protocol-relative or absolute paths,
+// data: URLs and fragment-only references are left alone, as is anything Match rejects.
+// Query strings and fragments (e.g. "font.eot?#iefix") are kept.  References to files
+// which do not exist in the Module are reported together as an error; references inside
+// /* comments */ are not looked at.
+type CSSURLRewriter struct {
+	// URL returns the URL for a file, e.g. (*webresource.ModuleHandler).URL.
+	URL func(m webresource.Module, fullPath string) (string, error)
+	// Match selects which referenced files are rewritten, all if nil (e.g. webresource.IsFont for fonts only).
+	Match func(fullPath string) bool
+}
+
+// TransformFile implements FileTransformer.
+func (r *CSSURLRewriter) TransformFile(m webresource.Module, fullPath string, content []byte) ([]byte, error) {
+
+	var missing []string
+	var err error
+	ret := outsideCSSComments(content, func(code []byte) []byte {
+		return cssURLRE.ReplaceAllFunc(code, func(b []byte) []byte {
+			if err != nil {
+				return b
+			}
+			subm := cssURLRE.FindSubmatch(b)
+			quote, ref := string(subm[1]), string(subm[2])
+			if quote != string(subm[3]) {
+				return b // mismatched quotes, not ours to fix
+			}
+
+			refPath, suffix := splitCSSRef(ref)
+			if !isRelativeCSSRef(refPath) {
+				return b
+			}
+			target := path.Join(path.Dir(fullPath), refPath)
+			if r.Match != nil && !r.Match(target) {
+				return b
+			}
+			if !fileExists(m, target) {
+				missing = append(missing, ref)
+				return b
+			}
+
+			var u string
+			u, err = r.URL(m, target)
+			if err != nil {
+				return b
+			}
+			return []byte("url(" + quote + u + suffix + quote + ")")
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("url() references not found in module %q: %s", m.Name(), strings.Join(missing, ", "))
+	}
+
+	return ret, nil
+}
+
+// outsideCSSComments returns content with fn applied to each part of it which is not
+// in a comment, comments are kept as they are.  An unterminated comment runs to the end.
+func outsideCSSComments(content []byte, fn func(code []byte) []byte) []byte {
+	var ret []byte
+	for len(content) > 0 {
+		start := bytes.Index(content, []byte("/*"))
+		if start < 0 {
+			break
+		}
+		end := bytes.Index(content[start+2:], []byte("*/"))
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start + 4
+		}
+		ret = append(ret, fn(content[:start])...)
+		ret = append(ret, content[start:end]...)
+		content = content[end:]
+	}
+	return append(ret, fn(content)...)
+}
+
+// splitCSSRef splits "a.eot?#iefix" into "a.eot" and "?#iefix"
+func splitCSSRef(ref string) (string, string) {
+	ref = strings.TrimSpace(ref)
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		return ref[:i], ref[i:]
+	}
+	return ref, ""
+}
+
+// isRelativeCSSRef returns true for references relative to the CSS file itself
+func isRelativeCSSRef(ref string) bool {
+	if ref == "" || strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "data:") {
+		return false
+	}
+	if i := strings.IndexAny(ref, ":/"); i >= 0 && ref[i] == ':' {
+		return false // has a scheme
+	}
+	return true
+}
+
+func fileExists(m webresource.Module, fullPath string) bool {
+	f, err := m.Open(fullPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	return err == nil && !fi.IsDir()
+}
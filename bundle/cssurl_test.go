@@ -0,0 +1,98 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+func TestCSSURLRewriter(t *testing.T) {
+
+	css := `@font-face {
+  font-family: 'FontAwesome';
+  src: url('../fonts/fa.eot?v=4.7.0');
+  src: url('../fonts/fa.eot?#iefix&v=4.7.0') format('embedded-opentype'), url("../fonts/fa.woff2") format('woff2');
+}
+.a { background: url(img/a.png); }
+.b { background: url(data:image/png;base64,AAAA); }
+.c { src: url(https://example.com/x.woff2); }
+`
+
+	now := time.Now()
+	m := webresource.NewFileSet("example.com/fa").
+		Mkdir("/css", 0755).
+		Mkdir("/css/img", 0755).
+		Mkdir("/fonts", 0755).
+		WriteFile("/css/fa.css", 0644, now, []byte(css)).
+		WriteFile("/css/img/a.png", 0644, now, nil).
+		WriteFile("/fonts/fa.eot", 0644, now, nil).
+		WriteFile("/fonts/fa.woff2", 0644, now, nil)
+
+	h := webresource.Handler(webresource.ModuleList{m}, webresource.HandlerOptions{})
+
+	b, err := NewBundler(webresource.ModuleList{m}, ".css").
+		AddFileTransformer(".css", &CSSURLRewriter{URL: h.URL}).
+		BuildExt(".css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `@font-face {
+  font-family: 'FontAwesome';
+  src: url('/_wr/example.com/fa/fonts/fa.eot?v=4.7.0');
+  src: url('/_wr/example.com/fa/fonts/fa.eot?#iefix&v=4.7.0') format('embedded-opentype'), url("/_wr/example.com/fa/fonts/fa.woff2") format('woff2');
+}
+.a { background: url(/_wr/example.com/fa/css/img/a.png); }
+.b { background: url(data:image/png;base64,AAAA); }
+.c { src: url(https://example.com/x.woff2); }
+
+`
+	if string(b.Content) != expected {
+		t.Fatalf("unexpected output:\n%s", b.Content)
+	}
+
+	// Match restricts which references are rewritten
+	b, err = NewBundler(webresource.ModuleList{m}, ".css").
+		AddFileTransformer(".css", &CSSURLRewriter{URL: h.URL, Match: webresource.IsFont}).
+		BuildExt(".css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b.Content), "url(img/a.png)") || !strings.Contains(string(b.Content), "/_wr/example.com/fa/fonts/fa.woff2") {
+		t.Fatalf("unexpected output with Match:\n%s", b.Content)
+	}
+
+	// fingerprinted URLs from the handler
+	hf := webresource.Handler(webresource.ModuleList{m}, webresource.HandlerOptions{Fingerprint: true})
+	out, err := (&CSSURLRewriter{URL: hf.URL}).TransformFile(m, "/css/fa.css", []byte(`.a { background: url("img/a.png"); }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pngURL, _ := hf.URL(m, "/css/img/a.png")
+	if string(out) != `.a { background: url("`+pngURL+`"); }` {
+		t.Fatalf("unexpected fingerprinted output: %s", out)
+	}
+
+	// missing targets are an error
+	_, err = (&CSSURLRewriter{URL: h.URL}).TransformFile(m, "/css/fa.css", []byte(`.d { src: url(missing.woff); } .e { src: url(../nope.png) }`))
+	if err == nil || !strings.Contains(err.Error(), "missing.woff, ../nope.png") {
+		t.Fatalf("expected error listing missing targets, got: %v", err)
+	}
+
+	// references in comments are left alone, missing or not
+	in := `/* .old { src: url(missing.woff); } */
+.a { background: url(img/a.png); } /* url(img/a.png) */ .b { background: url(img/a.png) }
+/* unterminated url(nope.png)`
+	out, err = (&CSSURLRewriter{URL: h.URL}).TransformFile(m, "/css/fa.css", []byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `/* .old { src: url(missing.woff); } */
+.a { background: url(/_wr/example.com/fa/css/img/a.png); } /* url(img/a.png) */ .b { background: url(/_wr/example.com/fa/css/img/a.png) }
+/* unterminated url(nope.png)` {
+		t.Fatalf("unexpected output with comments:\n%s", out)
+	}
+
+}
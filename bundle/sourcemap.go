@@ -0,0 +1,354 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gocaveman/webresource"
+)
+
+// SourceMapMode selects if and how source maps are emitted for bundles.
+type SourceMapMode int
+
+const (
+	SourceMapNone     SourceMapMode = iota // no source map (default)
+	SourceMapInline                        // map is appended to the bundle as a data: URL
+	SourceMapExternal                      // map is returned in Bundle.SourceMap and referenced by URL
+)
+
+// SourceMap is a Source Map revision 3 document.
+type SourceMap struct {
+	Version        int      `json:"version"`
+	File           string   `json:"file,omitempty"`
+	SourceRoot     string   `json:"sourceRoot,omitempty"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent,omitempty"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
+}
+
+// matches a sourceMappingURL comment in either JS or CSS form, the URL is submatch 1
+var sourceMappingURLRE = regexp.MustCompile(`^\s*(?://[#@]\s*sourceMappingURL=(\S+)\s*|/\*[#@]\s*sourceMappingURL=(\S+)\s*\*/\s*)$`)
+
+// mapSegment is one decoded mapping segment with absolute (not delta) values
+type mapSegment struct {
+	genCol  int
+	source  int
+	srcLine int
+	srcCol  int
+	name    int
+	fields  int // 1, 4 or 5
+}
+
+// sourceMapBuilder accumulates mappings for a bundle as files are appended to it
+type sourceMapBuilder struct {
+	sm          SourceMap
+	sourceIndex map[string]int
+	nameIndex   map[string]int
+	lines       [][]mapSegment
+}
+
+func newSourceMapBuilder(file string) *sourceMapBuilder {
+	return &sourceMapBuilder{
+		sm:          SourceMap{Version: 3, File: file},
+		sourceIndex: make(map[string]int),
+		nameIndex:   make(map[string]int),
+	}
+}
+
+func (b *sourceMapBuilder) addSource(name, content string) int {
+	if i, ok := b.sourceIndex[name]; ok {
+		return i
+	}
+	i := len(b.sm.Sources)
+	b.sm.Sources = append(b.sm.Sources, name)
+	b.sm.SourcesContent = append(b.sm.SourcesContent, content)
+	b.sourceIndex[name] = i
+	return i
+}
+
+func (b *sourceMapBuilder) addName(name string) int {
+	if i, ok := b.nameIndex[name]; ok {
+		return i
+	}
+	i := len(b.sm.Names)
+	b.sm.Names = append(b.sm.Names, name)
+	b.nameIndex[name] = i
+	return i
+}
+
+// addFile records the mappings for a file that starts at output line startLine.
+// If the module ships its own map for the file (referenced by a sourceMappingURL comment)
+// that map is composed in, otherwise each line maps to the same line of the file.
+// A map which cannot be read or decoded is logged and the file mapped to itself, a broken
+// reference in a third party module should not fail the bundle.
+// The returned content has any sourceMappingURL comment blanked out, line count is unchanged.
+func (b *sourceMapBuilder) addFile(m webresource.Module, fullPath string, content []byte, startLine int) ([]byte, error) {
+
+	lines := strings.Split(string(content), "\n")
+
+	var inMap *SourceMap
+	var inLines [][]mapSegment
+	for i, line := range lines {
+		subm := sourceMappingURLRE.FindStringSubmatch(line)
+		if subm == nil {
+			continue
+		}
+		mapURL := subm[1] + subm[2]
+		lines[i] = ""
+		var err error
+		inMap, err = readInputMap(m, fullPath, mapURL)
+		if err == nil {
+			inLines, err = decodeMappings(inMap.Mappings)
+		}
+		if err != nil {
+			log.Printf("bundle: ignoring source map %q for %s%s: %v", mapURL, m.Name(), fullPath, err)
+			inMap = nil
+		}
+	}
+	content = []byte(strings.Join(lines, "\n"))
+
+	for len(b.lines) < startLine+len(lines) {
+		b.lines = append(b.lines, nil)
+	}
+
+	if inMap == nil {
+		src := b.addSource(m.Name()+fullPath, string(content))
+		for i, line := range lines {
+			if line == "" {
+				continue
+			}
+			b.lines[startLine+i] = []mapSegment{{genCol: 0, source: src, srcLine: i, srcCol: 0, fields: 4}}
+		}
+		return content, nil
+	}
+
+	// map input sources and names to our own indexes
+	srcMap := make([]int, len(inMap.Sources))
+	for i, s := range inMap.Sources {
+		name := s
+		if !strings.Contains(s, "://") {
+			name = m.Name() + path.Join(path.Dir(fullPath), inMap.SourceRoot, s)
+		}
+		var sc string
+		if i < len(inMap.SourcesContent) {
+			sc = inMap.SourcesContent[i]
+		}
+		srcMap[i] = b.addSource(name, sc)
+	}
+	nameMap := make([]int, len(inMap.Names))
+	for i, n := range inMap.Names {
+		nameMap[i] = b.addName(n)
+	}
+
+	for i, segs := range inLines {
+		if i >= len(lines) {
+			break
+		}
+		out := make([]mapSegment, 0, len(segs))
+		for _, seg := range segs {
+			if seg.fields >= 4 {
+				if seg.source >= len(srcMap) {
+					return nil, fmt.Errorf("source map for %s%s references unknown source %d", m.Name(), fullPath, seg.source)
+				}
+				seg.source = srcMap[seg.source]
+			}
+			if seg.fields >= 5 {
+				if seg.name >= len(nameMap) {
+					return nil, fmt.Errorf("source map for %s%s references unknown name %d", m.Name(), fullPath, seg.name)
+				}
+				seg.name = nameMap[seg.name]
+			}
+			out = append(out, seg)
+		}
+		b.lines[startLine+i] = out
+	}
+
+	return content, nil
+}
+
+// readInputMap loads a map referenced from a module file, either a data: URL or a path relative to the file
+func readInputMap(m webresource.Module, fullPath string, mapURL string) (*SourceMap, error) {
+
+	var b []byte
+
+	if strings.HasPrefix(mapURL, "data:") {
+		i := strings.Index(mapURL, ",")
+		if i < 0 || !strings.HasSuffix(mapURL[:i], ";base64") {
+			return nil, fmt.Errorf("unsupported data URL")
+		}
+		var err error
+		b, err = base64.StdEncoding.DecodeString(mapURL[i+1:])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if strings.Contains(mapURL, "://") || strings.HasPrefix(mapURL, "/") {
+			return nil, fmt.Errorf("map must be relative to the file within the same module")
+		}
+		f, err := m.Open(path.Join(path.Dir(fullPath), mapURL))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		b, err = ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var sm SourceMap
+	err := json.Unmarshal(b, &sm)
+	if err != nil {
+		return nil, err
+	}
+	if sm.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version %d", sm.Version)
+	}
+	return &sm, nil
+}
+
+// build encodes the mappings and returns the JSON map
+func (b *sourceMapBuilder) build() ([]byte, error) {
+	b.sm.Mappings = encodeMappings(b.lines)
+	if b.sm.Names == nil {
+		b.sm.Names = []string{}
+	}
+	if b.sm.Sources == nil {
+		b.sm.Sources = []string{}
+	}
+	return json.Marshal(&b.sm)
+}
+
+// sourceMappingComment returns the comment referencing a map in the syntax for ext.
+func sourceMappingComment(ext string, mapURL string) string {
+	if ext == ".css" {
+		return "/*# sourceMappingURL=" + mapURL + " */\n"
+	}
+	return "//# sourceMappingURL=" + mapURL + "\n"
+}
+
+// inlineMapURL returns the data: URL for a JSON map.
+func inlineMapURL(mapJSON []byte) string {
+	return "data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(mapJSON)
+}
+
+func encodeMappings(lines [][]mapSegment) string {
+	var buf bytes.Buffer
+	var source, srcLine, srcCol, name int
+	for i, segs := range lines {
+		if i > 0 {
+			buf.WriteByte(';')
+		}
+		genCol := 0
+		for j, seg := range segs {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			writeVLQ(&buf, seg.genCol-genCol)
+			genCol = seg.genCol
+			if seg.fields < 4 {
+				continue
+			}
+			writeVLQ(&buf, seg.source-source)
+			writeVLQ(&buf, seg.srcLine-srcLine)
+			writeVLQ(&buf, seg.srcCol-srcCol)
+			source, srcLine, srcCol = seg.source, seg.srcLine, seg.srcCol
+			if seg.fields < 5 {
+				continue
+			}
+			writeVLQ(&buf, seg.name-name)
+			name = seg.name
+		}
+	}
+	return buf.String()
+}
+
+func decodeMappings(s string) ([][]mapSegment, error) {
+	var ret [][]mapSegment
+	var source, srcLine, srcCol, name int
+	for _, line := range strings.Split(s, ";") {
+		var segs []mapSegment
+		genCol := 0
+		for _, segStr := range strings.Split(line, ",") {
+			if segStr == "" {
+				continue
+			}
+			vals, err := readVLQs(segStr)
+			if err != nil {
+				return nil, err
+			}
+			if len(vals) != 1 && len(vals) != 4 && len(vals) != 5 {
+				return nil, fmt.Errorf("invalid segment %q", segStr)
+			}
+			genCol += vals[0]
+			seg := mapSegment{genCol: genCol, fields: len(vals)}
+			if len(vals) >= 4 {
+				source += vals[1]
+				srcLine += vals[2]
+				srcCol += vals[3]
+				seg.source, seg.srcLine, seg.srcCol = source, srcLine, srcCol
+			}
+			if len(vals) >= 5 {
+				name += vals[4]
+				seg.name = name
+			}
+			segs = append(segs, seg)
+		}
+		ret = append(ret, segs)
+	}
+	return ret, nil
+}
+
+const vlqChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+func writeVLQ(buf *bytes.Buffer, v int) {
+	u := v << 1
+	if v < 0 {
+		u = (-v << 1) | 1
+	}
+	for {
+		digit := u & 31
+		u >>= 5
+		if u > 0 {
+			digit |= 32
+		}
+		buf.WriteByte(vlqChars[digit])
+		if u == 0 {
+			break
+		}
+	}
+}
+
+func readVLQs(s string) ([]int, error) {
+	var ret []int
+	var v, shift int
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(vlqChars, s[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid VLQ character %q", s[i])
+		}
+		v += (digit & 31) << uint(shift)
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+		if v&1 != 0 {
+			ret = append(ret, -(v >> 1))
+		} else {
+			ret = append(ret, v>>1)
+		}
+		v, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, fmt.Errorf("truncated VLQ in %q", s)
+	}
+	return ret, nil
+}
@@ -0,0 +1,139 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+func TestVLQ(t *testing.T) {
+
+	vals := []int{0, 1, -1, 15, -16, 16, 1000, -123456}
+	var buf bytes.Buffer
+	for _, v := range vals {
+		writeVLQ(&buf, v)
+	}
+	out, err := readVLQs(buf.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vals, out) {
+		t.Fatalf("round trip failed, expected(%v) actual(%v)", vals, out)
+	}
+
+	// known encoding
+	buf.Reset()
+	writeVLQ(&buf, 16)
+	if buf.String() != "gB" {
+		t.Fatalf("unexpected encoding of 16: %q", buf.String())
+	}
+
+}
+
+func TestSourceMap(t *testing.T) {
+
+	now := time.Now()
+
+	// b.js ships a map that says its two lines came from lines 10 and 20 of src/b.ts
+	bMap := `{"version":3,"sources":["src/b.ts"],"names":["foo"],"mappings":"AASA;AAUAA"}`
+
+	a := webresource.NewFileSet("example.com/a").
+		WriteFile("/a.js", 0644, now, []byte("var a = 1;\nvar a2 = 2;"))
+	b := webresource.NewFileSet("example.com/b", a).
+		Mkdir("/dist", 0755).
+		WriteFile("/dist/b.js", 0644, now, []byte("var b = 1;\nfoo();\n//# sourceMappingURL=b.js.map\n")).
+		WriteFile("/dist/b.js.map", 0644, now, []byte(bMap))
+
+	ml := webresource.Resolve(webresource.ModuleList{b})
+
+	bundle, err := NewBundler(ml, ".js").
+		SetSourceMap(SourceMapExternal, func(ext string) string { return "/combined" + ext + ".map" }).
+		BuildExt(".js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := string(bundle.Content)
+	if strings.Contains(content, "b.js.map") {
+		t.Fatalf("input sourceMappingURL comment not removed: %q", content)
+	}
+	if !strings.HasSuffix(content, "//# sourceMappingURL=/combined.js.map\n") {
+		t.Fatalf("missing sourceMappingURL comment: %q", content)
+	}
+
+	var sm SourceMap
+	err = json.Unmarshal(bundle.SourceMap, &sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sm.Sources, []string{"example.com/a/a.js", "example.com/b/dist/src/b.ts"}) {
+		t.Fatalf("unexpected sources: %v", sm.Sources)
+	}
+
+	lines, err := decodeMappings(sm.Mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// output: a.js line 0, a.js line 1, b.js line 0 (b.ts 9), b.js line 1 (b.ts 19)
+	expect := [][2]int{{0, 0}, {0, 1}, {1, 9}, {1, 19}}
+	for i, e := range expect {
+		if len(lines[i]) != 1 || lines[i][0].source != e[0] || lines[i][0].srcLine != e[1] {
+			t.Fatalf("unexpected mapping on line %d: %+v", i, lines[i])
+		}
+	}
+	if lines[3][0].fields != 5 || sm.Names[lines[3][0].name] != "foo" {
+		t.Fatalf("name not carried over: %+v", lines[3][0])
+	}
+
+	// inline mode embeds the map as a data URL
+	bundle, err = NewBundler(ml, ".js").SetSourceMap(SourceMapInline, nil).BuildExt(".js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bundle.Content), "//# sourceMappingURL=data:application/json;charset=utf-8;base64,") {
+		t.Fatalf("missing inline source map: %q", bundle.Content)
+	}
+
+}
+
+func TestSourceMapMissingInput(t *testing.T) {
+
+	now := time.Now()
+
+	// c.js references a map which is not shipped, d.js one which is not JSON
+	c := webresource.NewFileSet("example.com/c").
+		WriteFile("/c.js", 0644, now, []byte("var c = 1;\n//# sourceMappingURL=c.js.map\n")).
+		WriteFile("/d.js", 0644, now, []byte("var d = 1;\n//# sourceMappingURL=d.js.map\n")).
+		WriteFile("/d.js.map", 0644, now, []byte("not json"))
+
+	bundle, err := NewBundler(webresource.ModuleList{c}, ".js").
+		SetSourceMap(SourceMapExternal, func(ext string) string { return "/combined" + ext + ".map" }).
+		BuildExt(".js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bundle.Content), "c.js.map") {
+		t.Fatalf("input sourceMappingURL comment not removed: %q", bundle.Content)
+	}
+
+	var sm SourceMap
+	err = json.Unmarshal(bundle.SourceMap, &sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sm.Sources, []string{"example.com/c/c.js", "example.com/c/d.js"}) {
+		t.Fatalf("unexpected sources: %v", sm.Sources)
+	}
+	lines, err := decodeMappings(sm.Mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines[0]) != 1 || lines[0][0].source != 0 || lines[0][0].srcLine != 0 {
+		t.Fatalf("c.js not mapped to itself: %+v", lines[0])
+	}
+}
@@ -0,0 +1,184 @@
+package webresource
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// CSPOptions configures NewCSP.
+type CSPOptions struct {
+	Modules      ModuleList // Resolve()d modules, the integrity hash of each JS and CSS file is allowed
+	ScriptURLs   []string   // URLs scripts are served from (e.g. bundles), the origin of each is allowed
+	StyleURLs    []string   // URLs styles are served from (e.g. bundles), the origin of each is allowed
+	IntegrityAlg string     // hash algorithm, DefaultIntegrityAlg if empty
+
+	Nonce      bool                // include a per-request 'nonce-...' source in script-src and style-src
+	Directives map[string][]string // additional directives and sources, e.g. "default-src": {"'self'"}
+	ReportOnly bool                // Middleware sets Content-Security-Policy-Report-Only instead
+}
+
+// NewCSP computes the sources allowed for the modules and URLs given.
+func NewCSP(opts CSPOptions) (*CSP, error) {
+
+	alg := opts.IntegrityAlg
+	if alg == "" {
+		alg = DefaultIntegrityAlg
+	}
+
+	c := &CSP{opts: opts}
+
+	var err error
+	c.scriptSrc, err = cspSources(opts.Modules, ".js", alg, opts.ScriptURLs)
+	if err != nil {
+		return nil, err
+	}
+	c.styleSrc, err = cspSources(opts.Modules, ".css", alg, opts.StyleURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// CSP builds Content-Security-Policy header values from resolved modules.
+type CSP struct {
+	opts      CSPOptions
+	scriptSrc []string
+	styleSrc  []string
+}
+
+// cspSources returns the hash sources for files with ext and the origins of urls, in a stable sequence
+func cspSources(ml ModuleList, ext string, alg string, urls []string) ([]string, error) {
+
+	var ret []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			ret = append(ret, s)
+		}
+	}
+
+	for _, u := range urls {
+		pu, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %v", u, err)
+		}
+		if pu.Host == "" {
+			add("'self'")
+		} else if pu.Scheme == "" {
+			add(pu.Host)
+		} else {
+			add(pu.Scheme + "://" + pu.Host)
+		}
+	}
+
+	for _, m := range ml {
+		hashes, err := ModuleIntegrity(m, alg, ext)
+		if err != nil {
+			return nil, err
+		}
+		paths := make([]string, 0, len(hashes))
+		for p := range hashes {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			add("'" + hashes[p] + "'")
+		}
+	}
+
+	return ret, nil
+}
+
+// Header returns the header value, with nonce included if it is not empty.
+func (c *CSP) Header(nonce string) string {
+
+	directives := make(map[string][]string, len(c.opts.Directives)+2)
+	for k, v := range c.opts.Directives {
+		directives[k] = append([]string(nil), v...)
+	}
+	for _, d := range []struct {
+		name    string
+		sources []string
+	}{{"script-src", c.scriptSrc}, {"style-src", c.styleSrc}} {
+		srcs := append(directives[d.name], d.sources...)
+		if nonce != "" {
+			srcs = append(srcs, "'nonce-"+nonce+"'")
+		}
+		if len(srcs) > 0 {
+			directives[d.name] = srcs
+		}
+	}
+
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(name)
+		for _, src := range directives[name] {
+			buf.WriteString(" " + src)
+		}
+	}
+	return buf.String()
+}
+
+// Middleware sets the Content-Security-Policy header on each response.  If nonces are
+// enabled a new nonce is generated per request and stored in the request context,
+// see NonceFromContext.
+func (c *CSP) Middleware(next http.Handler) http.Handler {
+	headerName := "Content-Security-Policy"
+	if c.opts.ReportOnly {
+		headerName = "Content-Security-Policy-Report-Only"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var nonce string
+		if c.opts.Nonce {
+			var err error
+			nonce, err = NewNonce()
+			if err != nil {
+				http.Error(w, "error generating nonce", http.StatusInternalServerError)
+				return
+			}
+			r = r.WithContext(ContextWithNonce(r.Context(), nonce))
+		}
+		w.Header().Set(headerName, c.Header(nonce))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewNonce returns a new random nonce suitable for a CSP 'nonce-...' source.
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+type nonceContextKey struct{}
+
+// ContextWithNonce returns a new context with nonce set.
+func ContextWithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceContextKey{}, nonce)
+}
+
+// NonceFromContext returns the nonce set by Middleware or ContextWithNonce, "" if none.
+func NonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceContextKey{}).(string)
+	return nonce
+}
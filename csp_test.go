@@ -0,0 +1,60 @@
+package webresource
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSP(t *testing.T) {
+
+	a := NewFileSet("example.com/a").
+		WriteFile("/a.js", 0644, time.Now(), []byte(`/* a.js */`)).
+		WriteFile("/a.css", 0644, time.Now(), []byte(`/* a.css */`))
+	aJS, _ := Integrity("sha384", []byte(`/* a.js */`))
+	aCSS, _ := Integrity("sha384", []byte(`/* a.css */`))
+
+	csp, err := NewCSP(CSPOptions{
+		Modules:    ModuleList{a},
+		ScriptURLs: []string{"/assets/combined.js", "https://cdn.example.com/combined.js"},
+		StyleURLs:  []string{"/assets/combined.css"},
+		Nonce:      true,
+		Directives: map[string][]string{"default-src": {"'self'"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "default-src 'self'; script-src 'self' https://cdn.example.com '" + aJS + "' 'nonce-abc'; style-src 'self' '" + aCSS + "' 'nonce-abc'"
+	if h := csp.Header("abc"); h != expected {
+		t.Fatalf("unexpected header:\n%s\nexpected:\n%s", h, expected)
+	}
+
+	// middleware puts the same nonce in the header and the request context for the template funcs
+	tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(TemplateOptions{Modules: ModuleList{a}, Handler: Handler(ModuleList{a}, HandlerOptions{})})).Parse(`{{wrScripts .}}`))
+	var body bytes.Buffer
+	h := csp.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := tmpl.Execute(&body, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	header := w.Header().Get("Content-Security-Policy")
+	i := strings.Index(header, "'nonce-")
+	if i < 0 {
+		t.Fatalf("no nonce in header: %s", header)
+	}
+	nonce := header[i+len("'nonce-"):]
+	nonce = nonce[:strings.Index(nonce, "'")]
+	if !strings.Contains(body.String(), `nonce="`+nonce+`"`) {
+		t.Fatalf("nonce %q not found in template output: %s", nonce, body.String())
+	}
+
+}
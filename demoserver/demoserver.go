@@ -4,31 +4,29 @@
 package main
 
 import (
-	"bytes"
 	"compress/gzip"
+	"context"
 	"flag"
-	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gocaveman-libs/bootstrap"
 	"github.com/gocaveman/webresource"
+	"github.com/gocaveman/webresource/bundle"
+	"github.com/gocaveman/webresource/dev"
 
 	"github.com/tdewolff/minify"
 	"github.com/tdewolff/minify/css"
 	"github.com/tdewolff/minify/js"
 )
 
-const maxAgeStr = "31536000" // 1 year
-
 func main() {
 
 	httpListen := flag.String("http", ":8080", "Host:port to listen for http server")
+	devDirs := flag.String("dev", "", "Enable development mode with live reload, reading the named modules from source directories (comma separated module=dir pairs)")
 	flag.Parse()
 
 	moduleList := webresource.Resolve(webresource.ModuleList{
@@ -36,98 +34,69 @@ func main() {
 		// add more modules here
 	})
 
+	// in development mode replace modules with their source directories
+	if *devDirs != "" {
+		for _, pair := range strings.Split(*devDirs, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				log.Fatalf("Invalid -dev value %q, expected module=dir", pair)
+			}
+			found := false
+			for i, m := range moduleList {
+				if m.Name() == parts[0] {
+					moduleList[i] = webresource.DirOverride(m, parts[1])
+					found = true
+				}
+			}
+			if !found {
+				log.Fatalf("Module %q from -dev not found", parts[0])
+			}
+		}
+	}
+
 	min := minify.New()
 	min.AddFunc("text/css", css.Minify)
 	min.AddFunc("application/javascript", js.Minify)
 
-	var err error
+	// concatenate and minify CSS and JS, each bundle records its most recent time
+	bundler := bundle.NewBundler(moduleList, ".css", ".js").
+		AddTransformer(".css", bundle.TransformerFunc(func(content []byte) ([]byte, error) {
+			return min.Bytes("text/css", content)
+		})).
+		AddTransformer(".js", bundle.TransformerFunc(func(content []byte) ([]byte, error) {
+			return min.Bytes("application/javascript", content)
+		}))
+	bundles, err := bundler.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// concatenate CSS, record most recent time
-	var cssBuf bytes.Buffer
-	var cssTime time.Time
-	err = moduleList.Walk(".css", func(m webresource.Module, fullPath string, f http.File) error {
-		b, err := ioutil.ReadAll(f)
-		if err != nil {
-			return err
-		}
-		st, err := f.Stat()
-		if err != nil {
-			return err
-		}
-		if st.ModTime().After(cssTime) {
-			cssTime = st.ModTime()
-		}
-		fmt.Fprintf(&cssBuf, "%s\n", b)
-		return nil
-	})
-	cssTimeStr := fmt.Sprintf("%d", cssTime.Unix())
-	var cssBufMin bytes.Buffer
-	min.Minify("text/css", &cssBufMin, &cssBuf)
-
-	// concatenate JS, record most recent time
-	var jsBuf bytes.Buffer
-	var jsTime time.Time
-	err = moduleList.Walk(".js", func(m webresource.Module, fullPath string, f http.File) error {
-		b, err := ioutil.ReadAll(f)
-		if err != nil {
-			return err
-		}
-		st, err := f.Stat()
-		if err != nil {
-			return err
-		}
-		if st.ModTime().After(jsTime) {
-			jsTime = st.ModTime()
-		}
-		fmt.Fprintf(&jsBuf, "%s\n", b)
-		return nil
-	})
-	jsTimeStr := fmt.Sprintf("%d", jsTime.Unix())
-	var jsBufMin bytes.Buffer
-	min.Minify("application/javascript", &jsBufMin, &jsBuf)
-
-	http.HandleFunc("/combined.css", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/css; charset=utf-8")
-		if r.URL.Query().Get("_") == cssTimeStr {
-			w.Header().Set("Cache-Control", "max-age="+maxAgeStr)
-		} else {
-			w.Header().Set("Cache-Control", "no-cache")
-		}
-		gw := gzipW(w, r)
-		if gw != nil {
-			w = gw
-			defer gw.Close()
-		}
-		http.ServeContent(w, r, "/combined.css", cssTime, bytes.NewReader(cssBufMin.Bytes()))
-	})
+	// serve bundles at content-hash fingerprinted URLs with immutable caching
+	assets := webresource.NewAssets("/assets/")
+	for _, b := range bundles {
+		assets.Add("combined"+b.Ext, b.ModTime, b.Content)
+	}
+	http.Handle("/assets/", gzipHandler(assets))
 
-	http.HandleFunc("/combined.js", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
-		if r.URL.Query().Get("_") == jsTimeStr {
-			w.Header().Set("Cache-Control", "max-age="+maxAgeStr)
-		} else {
-			w.Header().Set("Cache-Control", "no-cache")
-		}
-		gw := gzipW(w, r)
-		if gw != nil {
-			w = gw
-			defer gw.Close()
-		}
-		http.ServeContent(w, r, "/combined.js", jsTime, bytes.NewReader(jsBufMin.Bytes()))
+	tmplFuncs := webresource.TemplateFuncs(webresource.TemplateOptions{
+		Assets:       assets,
+		StyleBundle:  "combined.css",
+		ScriptBundle: "combined.js",
+		Integrity:    true,
 	})
 
-	hometmpl, err := template.New("_home_").Parse(`<!DOCTYPE html>
+	hometmpl, err := template.New("_home_").Funcs(tmplFuncs).Parse(`<!DOCTYPE html>
 <html>
 <head>
 	<title>demoserver - webresource server example</title>
-	<link rel="stylesheet" type="text/css" href="/combined.css?_={{.cssTimeStr}}">
+	{{wrStyles}}
 </head>
 <body>
 <div class="container">
 	<h1>Demo Server Page</h1>
 	<p>A simple example of using <code data-toggle="tooltip" title="" data-original-title="The webresource package is a prototype for JS and CSS dependencies in Go">webresource</code> to make a webserver.</p>
 </div>
-<script type="text/javascript" src="/combined.js?_={{.jsTimeStr}}"></script>
+{{wrScripts}}
 <script>
 $(function () {
 	$('[data-toggle="tooltip"]').tooltip(); // bootstrap made me do it: https://getbootstrap.com/docs/4.0/components/tooltips/
@@ -140,28 +109,63 @@ $(function () {
 		log.Fatal(err)
 	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	var homeHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Header().Set("Cache-Control", "no-cache")
-		gw := gzipW(w, r)
-		if gw != nil {
-			w = gw
-			defer gw.Close()
-		}
-		err := hometmpl.Execute(w, map[string]string{
-			"cssTimeStr": cssTimeStr,
-			"jsTimeStr":  jsTimeStr,
-		})
+		err := hometmpl.Execute(w, nil)
 		if err != nil {
 			log.Printf("Error executing page template: %v", err)
 		}
 	})
 
+	// tell the browser about the bundles before the page renders,
+	// looked up per request since development mode can change the URLs
+	homeHandler = webresource.NewPreloader(webresource.PreloadOptions{
+		PreloadsFunc: func(r *http.Request) []webresource.Preload {
+			preloads, err := webresource.AssetPreloads(assets, webresource.PreloadListOptions{Integrity: true}, "combined.css", "combined.js")
+			if err != nil {
+				log.Printf("Error getting preloads: %v", err)
+			}
+			return preloads
+		},
+		EarlyHints: true,
+	}).Middleware(homeHandler)
+
+	// development mode rebuilds bundles on change and injects the live reload script
+	if *devDirs != "" {
+		rl := dev.New(moduleList, dev.Options{
+			Bundler:     bundler,
+			Assets:      assets,
+			BundleNames: map[string]string{".css": "combined.css", ".js": "combined.js"},
+		})
+		go func() {
+			log.Fatal(rl.Run(context.Background()))
+		}()
+		http.Handle(dev.DefaultPrefix, rl)
+		// script is injected into the uncompressed page
+		homeHandler = rl.Middleware(homeHandler)
+	} else {
+		homeHandler = gzipHandler(homeHandler)
+	}
+	http.Handle("/", homeHandler)
+
 	log.Printf("Listening for HTTP at %s", *httpListen)
 	log.Fatal(http.ListenAndServe(*httpListen, nil))
 
 }
 
+// gzipHandler wraps h with gzip compression when supported by the client
+func gzipHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gw := gzipW(w, r)
+		if gw != nil {
+			w = gw
+			defer gw.Close()
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 // returns a gzipResponseWriter unless not supported then returns nil;
 // caller is responsible for calling Close()
 func gzipW(w http.ResponseWriter, r *http.Request) *gzipResponseWriter {
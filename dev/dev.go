@@ -0,0 +1,256 @@
+// Development mode with live reload: module files are watched for changes, bundles are
+// rebuilt and a small injected client script reloads CSS in place and reloads the page for JS.
+// Nothing here is enabled unless a Reloader is created and used, it is not intended for production.
+package dev
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocaveman/webresource"
+	"github.com/gocaveman/webresource/bundle"
+)
+
+// DefaultPrefix is the URL prefix for the Reloader's own endpoints when none is specified.
+const DefaultPrefix = "/_wr_dev/"
+
+// Options configures a Reloader.
+type Options struct {
+	Exts     []string      // file extensions to watch, ".css" and ".js" if empty
+	Interval time.Duration // polling interval, 500ms if zero
+	Prefix   string        // URL prefix for the client script and event stream, DefaultPrefix if empty
+
+	// If set, bundles for changed extensions are rebuilt with Bundler and re-added
+	// to Assets under the logical name from BundleNames, e.g. ".js": "combined.js".
+	Bundler     *bundle.Bundler
+	Assets      *webresource.Assets
+	BundleNames map[string]string
+}
+
+// New returns a Reloader for the modules given.  Call Run to start watching.
+func New(ml webresource.ModuleList, opts Options) *Reloader {
+	if len(opts.Exts) == 0 {
+		opts.Exts = []string{".css", ".js"}
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 500 * time.Millisecond
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	opts.Prefix = "/" + strings.Trim(opts.Prefix, "/") + "/"
+	return &Reloader{
+		opts:    opts,
+		watcher: NewWatcher(ml, opts.Interval, opts.Exts...),
+		clients: make(map[chan []byte]bool),
+	}
+}
+
+// Reloader watches modules, rebuilds bundles and notifies browsers of changes.
+// It is an http.Handler for its client script and event stream, which must be
+// mounted at the Prefix.
+type Reloader struct {
+	opts    Options
+	watcher *Watcher
+
+	mu      sync.Mutex
+	clients map[chan []byte]bool
+}
+
+// event is sent to the client script as JSON
+type event struct {
+	Type string            `json:"type"`           // "css" to reload stylesheets in place, "reload" for the page
+	URLs map[string]string `json:"urls,omitempty"` // old to new URL for rebuilt CSS bundles
+}
+
+// Run watches for changes until ctx is done.
+func (rl *Reloader) Run(ctx context.Context) error {
+	return rl.watcher.Run(ctx, func(changed []string) {
+		ev, err := rl.rebuild(changed)
+		if err != nil {
+			// keep running so fixing the error gets picked up
+			log.Printf("webresource/dev: error rebuilding after change to %v: %v", changed, err)
+			return
+		}
+		rl.broadcast(ev)
+	})
+}
+
+// rebuild rebuilds the bundles affected by changed and returns the event to send
+func (rl *Reloader) rebuild(changed []string) (event, error) {
+
+	exts := changedExts(changed)
+
+	ev := event{Type: "reload"}
+	if len(exts) == 1 && exts[".css"] {
+		ev = event{Type: "css", URLs: make(map[string]string)}
+	}
+
+	if rl.opts.Bundler == nil || rl.opts.Assets == nil {
+		return ev, nil
+	}
+
+	for ext := range exts {
+		name := rl.opts.BundleNames[ext]
+		if name == "" {
+			continue
+		}
+		b, err := rl.opts.Bundler.BuildExt(ext)
+		if err != nil {
+			return ev, err
+		}
+		oldURL, _ := rl.opts.Assets.URL(name)
+		asset := rl.opts.Assets.Add(name, b.ModTime, b.Content)
+		if ev.URLs != nil && oldURL != "" {
+			ev.URLs[oldURL] = asset.URL
+		}
+	}
+
+	return ev, nil
+}
+
+func (rl *Reloader) broadcast(ev event) {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for ch := range rl.clients {
+		select {
+		case ch <- b:
+		default: // slow client, it will get the next one
+		}
+	}
+}
+
+// ScriptURL returns the URL of the client script.
+func (rl *Reloader) ScriptURL() string { return rl.opts.Prefix + "client.js" }
+
+// ServeHTTP implements http.Handler and serves the client script and event stream.
+func (rl *Reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, rl.opts.Prefix) {
+	case "client.js":
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		fmt.Fprintf(w, clientScript, strconv.Quote(rl.opts.Prefix+"events"))
+	case "events":
+		rl.serveEvents(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (rl *Reloader) serveEvents(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan []byte, 1)
+	rl.mu.Lock()
+	rl.clients[ch] = true
+	rl.mu.Unlock()
+	defer func() {
+		rl.mu.Lock()
+		delete(rl.clients, ch)
+		rl.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case b := <-ch:
+			fmt.Fprintf(w, "data: %s\n\n", b)
+			flusher.Flush()
+		}
+	}
+}
+
+// Middleware injects the client script tag before </body> in HTML responses from next.
+// Requests under the Reloader's prefix are passed through untouched.
+func (rl *Reloader) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if strings.HasPrefix(r.URL.Path, rl.opts.Prefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+
+		body := bw.buf.Bytes()
+		if bw.status == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") && w.Header().Get("Content-Encoding") == "" {
+			tag := []byte(`<script src="` + rl.ScriptURL() + `"></script>`)
+			if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+				body = append(body[:i:i], append(tag, body[i:]...)...)
+			} else {
+				body = append(body, tag...)
+			}
+			w.Header().Del("Content-Length")
+		}
+
+		w.WriteHeader(bw.status)
+		w.Write(body)
+	})
+}
+
+// bufferedWriter holds the response body so it can be modified before sending
+type bufferedWriter struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+}
+
+// WriteHeader passes informational (1xx) responses such as 103 Early Hints straight through
+func (w *bufferedWriter) WriteHeader(status int) {
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) { return w.buf.Write(b) }
+
+// clientScript is formatted with the quoted event stream URL
+const clientScript = `(function() {
+	var es = new EventSource(%s);
+	es.onmessage = function(e) {
+		var ev = JSON.parse(e.data);
+		if (ev.type !== "css") {
+			location.reload();
+			return;
+		}
+		var links = document.querySelectorAll('link[rel="stylesheet"]');
+		for (var i = 0; i < links.length; i++) {
+			var link = links[i];
+			var href = link.getAttribute("href").split("?")[0];
+			var next = (ev.urls && ev.urls[href]) || href + "?_wr=" + Date.now();
+			var clone = link.cloneNode();
+			clone.removeAttribute("integrity"); // content changed
+			clone.setAttribute("href", next);
+			clone.onload = function() { this.old.parentNode.removeChild(this.old); };
+			clone.old = link;
+			link.parentNode.insertBefore(clone, link.nextSibling);
+		}
+	};
+})();
+`
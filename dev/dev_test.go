@@ -0,0 +1,137 @@
+package dev
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+	"github.com/gocaveman/webresource/bundle"
+)
+
+func TestReloaderRebuild(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "a.css"), []byte(`/* v1 */`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ml := webresource.ModuleList{webresource.NewDirModule("example.com/a", dir)}
+	bundler := bundle.NewBundler(ml, ".css")
+	assets := webresource.NewAssets("/assets/")
+	b, err := bundler.BuildExt(".css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldURL := assets.Add("combined.css", b.ModTime, b.Content).URL
+
+	rl := New(ml, Options{Bundler: bundler, Assets: assets, BundleNames: map[string]string{".css": "combined.css"}})
+
+	err = ioutil.WriteFile(filepath.Join(dir, "a.css"), []byte(`/* v2 changed */`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err := rl.rebuild([]string{"example.com/a/a.css"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newURL, _ := assets.URL("combined.css")
+	if ev.Type != "css" || ev.URLs[oldURL] != newURL || newURL == oldURL {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if string(assets.Named("combined.css").Content) != "/* v2 changed */\n" {
+		t.Fatalf("bundle not rebuilt: %q", assets.Named("combined.css").Content)
+	}
+
+	ev, err = rl.rebuild([]string{"example.com/a/a.css", "example.com/a/a.js"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "reload" {
+		t.Fatalf("expected page reload for JS change: %+v", ev)
+	}
+
+}
+
+func TestReloaderMiddleware(t *testing.T) {
+
+	rl := New(nil, Options{})
+
+	h := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".txt") {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("</body>"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body><p>hi</p></body></html>"))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != `<html><body><p>hi</p><script src="/_wr_dev/client.js"></script></body></html>` {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/a.txt", nil))
+	if w.Body.String() != `</body>` {
+		t.Fatalf("non-HTML response modified: %s", w.Body.String())
+	}
+
+	// client script
+	w = httptest.NewRecorder()
+	rl.ServeHTTP(w, httptest.NewRequest("GET", "/_wr_dev/client.js", nil))
+	if !strings.Contains(w.Body.String(), `new EventSource("/_wr_dev/events")`) {
+		t.Fatalf("unexpected client script: %s", w.Body.String())
+	}
+
+}
+
+func TestReloaderEvents(t *testing.T) {
+
+	rl := New(nil, Options{})
+	srv := httptest.NewServer(rl)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/_wr_dev/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	// wait for the client to be registered
+	for i := 0; i < 100; i++ {
+		rl.mu.Lock()
+		n := len(rl.clients)
+		rl.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rl.broadcast(event{Type: "reload"})
+
+	buf := make([]byte, 64)
+	n, err := res.Body.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "data: {\"type\":\"reload\"}\n\n" {
+		t.Fatalf("unexpected event: %q", buf[:n])
+	}
+
+}
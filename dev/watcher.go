@@ -0,0 +1,117 @@
+package dev
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+// NewWatcher returns a Watcher for the files with the extensions given in ml.
+// Changes are detected by polling ModTime and size, which works the same for any Module.
+func NewWatcher(ml webresource.ModuleList, interval time.Duration, exts ...string) *Watcher {
+	return &Watcher{
+		modules:  ml,
+		exts:     exts,
+		interval: interval,
+	}
+}
+
+// Watcher polls the files of a ModuleList for changes.
+type Watcher struct {
+	modules  webresource.ModuleList
+	exts     []string
+	interval time.Duration
+	state    map[string]fileState // keyed by module name + full path
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Scan checks all files and returns the names (module name + full path) of those added,
+// removed or changed since the previous call, sorted.  The first call records the
+// initial state and returns nothing.
+func (w *Watcher) Scan() ([]string, error) {
+
+	state := make(map[string]fileState)
+	for _, ext := range w.exts {
+		err := w.modules.Walk(ext, func(m webresource.Module, fullPath string, f http.File) error {
+			st, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			state[m.Name()+fullPath] = fileState{modTime: st.ModTime(), size: st.Size()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	prev := w.state
+	w.state = state
+	if prev == nil {
+		return nil, nil
+	}
+
+	var ret []string
+	for name, fs := range state {
+		pfs, ok := prev[name]
+		if !ok || !pfs.modTime.Equal(fs.modTime) || pfs.size != fs.size {
+			ret = append(ret, name)
+		}
+	}
+	for name := range prev {
+		if _, ok := state[name]; !ok {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+
+	return ret, nil
+}
+
+// Run calls Scan every interval until ctx is done, calling fn with the names of
+// changed files whenever there are any, and returns ctx.Err().  Errors from Scan are
+// logged and polling continues, since e.g. an editor saving a file by renaming over it
+// can make it disappear briefly; changes are then reported against the last good scan.
+func (w *Watcher) Run(ctx context.Context, fn func(changed []string)) error {
+
+	if _, err := w.Scan(); err != nil {
+		log.Printf("webresource/dev: error scanning for changes: %v", err)
+	}
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		changed, err := w.Scan()
+		if err != nil {
+			log.Printf("webresource/dev: error scanning for changes: %v", err)
+			continue
+		}
+		if len(changed) > 0 {
+			fn(changed)
+		}
+	}
+}
+
+// changedExts returns the distinct extensions of the names given
+func changedExts(names []string) map[string]bool {
+	ret := make(map[string]bool)
+	for _, name := range names {
+		ret[path.Ext(name)] = true
+	}
+	return ret
+}
@@ -0,0 +1,138 @@
+package dev
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+func TestWatcher(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string, modTime time.Time) {
+		p := filepath.Join(dir, name)
+		err := ioutil.WriteFile(p, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(p, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t1 := time.Unix(1500000000, 0)
+	write("a.js", `/* a.js */`, t1)
+	write("a.css", `/* a.css */`, t1)
+
+	w := NewWatcher(webresource.ModuleList{webresource.NewDirModule("example.com/a", dir)}, time.Second, ".js", ".css")
+
+	changed, err := w.Scan()
+	if err != nil || changed != nil {
+		t.Fatalf("unexpected first scan: %v %v", changed, err)
+	}
+
+	write("a.css", `/* a.css v2 */`, t1.Add(time.Second))
+	write("b.js", `/* b.js */`, t1)
+	os.Remove(filepath.Join(dir, "a.js"))
+
+	changed, err = w.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"example.com/a/a.css", "example.com/a/a.js", "example.com/a/b.js"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("unexpected changes: %v", changed)
+	}
+
+	changed, err = w.Scan()
+	if err != nil || changed != nil {
+		t.Fatalf("unexpected changes with no edits: %v %v", changed, err)
+	}
+
+}
+
+// flakyModule fails to open anything while failing is set
+type flakyModule struct {
+	webresource.Module
+	failing int32
+}
+
+func (m *flakyModule) Open(name string) (http.File, error) {
+	if atomic.LoadInt32(&m.failing) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return m.Module.Open(name)
+}
+
+func TestWatcherRunScanError(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "a.js")
+	err = ioutil.WriteFile(p, []byte(`/* a.js */`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	m := &flakyModule{Module: webresource.NewDirModule("example.com/a", dir), failing: 1}
+	w := NewWatcher(webresource.ModuleList{m}, 5*time.Millisecond, ".js")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changedc := make(chan []string, 10)
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx, func(changed []string) { changedc <- changed })
+	}()
+
+	// scans fail for a while, then the file changes after a good scan
+	time.Sleep(30 * time.Millisecond)
+	atomic.StoreInt32(&m.failing, 0)
+	time.Sleep(30 * time.Millisecond)
+	err = ioutil.WriteFile(p, []byte(`/* a.js v2 */`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case changed := <-changedc:
+		if !reflect.DeepEqual(changed, []string{"example.com/a/a.js"}) {
+			t.Errorf("unexpected changes: %v", changed)
+		}
+	case err := <-done:
+		t.Fatalf("Run returned early: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("change not detected")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if !bytes.Contains(logBuf.Bytes(), []byte("error scanning for changes")) {
+		t.Errorf("scan error not logged: %q", logBuf.String())
+	}
+}
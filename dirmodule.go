@@ -0,0 +1,69 @@
+package webresource
+
+import (
+	"net/http"
+)
+
+// NewDirModule returns a Module which reads its files from dir on disk each time they
+// are opened, so edits show up without regenerating anything.  Intended for development.
+func NewDirModule(name string, dir string, requires ...Module) *DirModule {
+	ret := &DirModule{
+		FileSystem: http.Dir(dir),
+		name:       name,
+		dir:        dir,
+	}
+	for _, r := range requires {
+		ret.requires = append(ret.requires, r)
+	}
+	return ret
+}
+
+// DirOverride returns a DirModule with the same Name(), Requires(), Manifest(), Metadata() and ESModules() as m
+// but which reads its files from dir, e.g. to work on a module's source directory
+// in place of its generated FileSet.
+func DirOverride(m Module, dir string) *DirModule {
+	ret := &DirModule{
+		FileSystem: http.Dir(dir),
+		name:       m.Name(),
+		dir:        dir,
+		requires:   m.Requires(),
+	}
+	if mf, ok := m.(Manifester); ok {
+		ret.manifest = mf.Manifest()
+	}
+	if mp, ok := m.(MetadataProvider); ok {
+		ret.metadata = mp.Metadata()
+	}
+	if esm, ok := m.(ESModuler); ok {
+		ret.esm = esm.ESModules()
+	}
+	return ret
+}
+
+// DirModule implements Module using a directory on disk.
+type DirModule struct {
+	http.FileSystem
+	name     string
+	dir      string
+	requires []interface{}
+	manifest []string
+	metadata map[string]string
+	esm      map[string]string
+}
+
+func (d *DirModule) Name() string            { return d.name }
+func (d *DirModule) Requires() []interface{} { return d.requires }
+
+// Dir returns the directory files are read from.
+func (d *DirModule) Dir() string { return d.dir }
+
+// Manifest implements Manifester.
+func (d *DirModule) Manifest() []string { return d.manifest }
+
+// Metadata implements MetadataProvider.
+func (d *DirModule) Metadata() map[string]string { return d.metadata }
+
+// ESModules implements ESModuler.
+func (d *DirModule) ESModules() map[string]string { return d.esm }
+
+func (d *DirModule) String() string { return d.name + " (" + d.dir + ")" }
@@ -0,0 +1,63 @@
+package webresource
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirModule(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "dirmodule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "a.js"), []byte(`/* v1 */`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := NewFileSet("example.com/req")
+	gen := NewFileSet("example.com/a", req).
+		WriteFile("/a.js", 0644, time.Now(), []byte(`/* generated */`)).
+		SetManifest("/a.js").
+		SetMetadata("license", "MIT").
+		SetESModule("a", "/a.js")
+	d := DirOverride(gen, dir)
+
+	if d.Name() != gen.Name() || len(d.Requires()) != 1 || len(d.Manifest()) != 1 || d.Metadata()["license"] != "MIT" || d.ESModules()["a"] != "/a.js" {
+		t.Fatalf("override does not match original: %s", d)
+	}
+
+	read := func() string {
+		var ret string
+		err := Walk(d, ".js", func(m Module, fullPath string, f http.File) error {
+			b, err := ioutil.ReadAll(f)
+			ret = string(b)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret
+	}
+
+	if v := read(); v != `/* v1 */` {
+		t.Fatalf("unexpected content: %s", v)
+	}
+
+	// edits show up immediately
+	err = ioutil.WriteFile(filepath.Join(dir, "a.js"), []byte(`/* v2 */`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := read(); v != `/* v2 */` {
+		t.Fatalf("unexpected content after edit: %s", v)
+	}
+
+}
@@ -0,0 +1,12 @@
+//go:build go1.19
+// +build go1.19
+
+package webresource
+
+import "net/http"
+
+// writeEarlyHints sends a 103 informational response with the headers set so far,
+// they remain set for the final response.
+func writeEarlyHints(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusEarlyHints)
+}
@@ -0,0 +1,52 @@
+//go:build go1.19
+// +build go1.19
+
+package webresource
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+)
+
+func TestEarlyHints(t *testing.T) {
+
+	pl := NewPreloader(PreloadOptions{
+		Preloads:   []Preload{{URL: "/a.css", As: "style"}},
+		EarlyHints: true,
+	})
+	srv := httptest.NewServer(pl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})))
+	defer srv.Close()
+
+	var hints textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = header
+			}
+			return nil
+		},
+	}
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if hints.Get("Link") != "</a.css>; rel=preload; as=style" {
+		t.Fatalf("unexpected early hints: %v", hints)
+	}
+	if res.StatusCode != 200 || res.Header.Get("Link") != "</a.css>; rel=preload; as=style" {
+		t.Fatalf("unexpected final response: %d %v", res.StatusCode, res.Header)
+	}
+
+}
@@ -0,0 +1,9 @@
+//go:build !go1.19
+// +build !go1.19
+
+package webresource
+
+import "net/http"
+
+// writeEarlyHints is a no-op, before Go 1.19 WriteHeader with a 1xx code would be the final response.
+func writeEarlyHints(w http.ResponseWriter) {}
@@ -0,0 +1,49 @@
+package webresource
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// FontExts lists the font file extensions which are handled specially, in order of preference.
+var FontExts = []string{".woff2", ".woff", ".ttf", ".otf", ".eot"}
+
+// fontTypes maps font file extensions to their MIME types, these are not reliably
+// present in the system MIME tables
+var fontTypes = map[string]string{
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".eot":   "application/vnd.ms-fontobject",
+}
+
+// IsFont returns true if name has a font file extension.
+func IsFont(name string) bool {
+	_, ok := fontTypes[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// ContentType returns the MIME type for name based on its extension, "" if not known.
+// Font types are built in, others come from mime.TypeByExtension.
+func ContentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ct, ok := fontTypes[ext]; ok {
+		return ct
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// setFontHeaders sets the CORS header needed for cross-origin font loads, if name is a font
+func setFontHeaders(w http.ResponseWriter, name string, origin string) {
+	if !IsFont(name) {
+		return
+	}
+	if origin == "" {
+		origin = "*"
+	}
+	// the value does not depend on the request, so no Vary: Origin
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+}
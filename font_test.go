@@ -0,0 +1,69 @@
+package webresource
+
+import (
+	"bytes"
+	"html/template"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFont(t *testing.T) {
+
+	if ContentType("/a/b.woff2") != "font/woff2" || ContentType("/a/b.EOT") != "application/vnd.ms-fontobject" {
+		t.Fatalf("unexpected font content types")
+	}
+	if IsFont("/a/b.css") {
+		t.Fatalf("css is not a font")
+	}
+
+	m := NewFileSet("example.com/fa").
+		Mkdir("/fonts", 0755).
+		WriteFile("/fonts/fa.woff2", 0644, time.Now(), []byte("wOF2")).
+		WriteFile("/fonts/fa.woff", 0644, time.Now(), []byte("wOFF")).
+		WriteFile("/fonts/fa.ttf", 0644, time.Now(), []byte("ttf"))
+	ml := ModuleList{m}
+	h := Handler(ml, HandlerOptions{})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/_wr/example.com/fa/fonts/fa.woff2", nil))
+	if w.Header().Get("Content-Type") != "font/woff2" || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("unexpected font headers: %v", w.Header())
+	}
+
+	hOrigin := Handler(ml, HandlerOptions{FontOrigin: "https://example.com"})
+	w = httptest.NewRecorder()
+	hOrigin.ServeHTTP(w, httptest.NewRequest("GET", "/_wr/example.com/fa/fonts/fa.woff2", nil))
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" || w.Header().Get("Vary") != "" {
+		t.Fatalf("unexpected font headers with FontOrigin: %v", w.Header())
+	}
+
+	p, err := PreloadFor("/fonts/fa.woff2?v=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.LinkHeader() != `</fonts/fa.woff2?v=1>; rel=preload; as=font; type="font/woff2"; crossorigin` {
+		t.Fatalf("unexpected font preload: %s", p.LinkHeader())
+	}
+
+	tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(TemplateOptions{Modules: ml, Handler: h})).Parse(`{{wrFontPreloads}}`))
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != `<link rel="preload" href="/_wr/example.com/fa/fonts/fa.woff2" as="font" type="font/woff2" crossorigin>`+"\n" {
+		t.Fatalf("unexpected template output: %s", buf.String())
+	}
+
+	tmpl = template.Must(template.New("t").Funcs(TemplateFuncs(TemplateOptions{Modules: ml, Handler: h, FontPreloadExt: ".woff"})).Parse(`{{wrFontPreloads}}`))
+	buf.Reset()
+	err = tmpl.Execute(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != `<link rel="preload" href="/_wr/example.com/fa/fonts/fa.woff" as="font" type="font/woff" crossorigin>`+"\n" {
+		t.Fatalf("unexpected template output with FontPreloadExt: %s", buf.String())
+	}
+
+}
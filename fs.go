@@ -56,6 +56,9 @@ type FileSet struct {
 	root     *fileEntry
 	name     string
 	requires []Module
+	manifest []string
+	esm      map[string]string
+	metadata map[string]string
 }
 
 func (fs *FileSet) Name() string { return fs.name }
@@ -67,6 +70,41 @@ func (fs *FileSet) Requires() []interface{} {
 	return ret
 }
 
+// Manifest implements Manifester.
+func (fs *FileSet) Manifest() []string { return fs.manifest }
+
+// SetManifest sets the sequence in which Walk() will visit the files in this FileSet.
+// Paths not listed are visited afterward in the order they were created.
+func (fs *FileSet) SetManifest(fullPaths ...string) *FileSet {
+	fs.manifest = fullPaths
+	return fs
+}
+
+// ESModules implements ESModuler.
+func (fs *FileSet) ESModules() map[string]string { return fs.esm }
+
+// SetESModule declares the bare import specifier for an ES module entry point in this FileSet,
+// e.g. SetESModule("lit", "/index.js").  See ESModuler.
+func (fs *FileSet) SetESModule(specifier string, fullPath string) *FileSet {
+	if fs.esm == nil {
+		fs.esm = make(map[string]string)
+	}
+	fs.esm[specifier] = fullPath
+	return fs
+}
+
+// Metadata implements MetadataProvider.
+func (fs *FileSet) Metadata() map[string]string { return fs.metadata }
+
+// SetMetadata sets a metadata value for this FileSet, e.g. SetMetadata("license", "MIT").
+func (fs *FileSet) SetMetadata(key, value string) *FileSet {
+	if fs.metadata == nil {
+		fs.metadata = make(map[string]string)
+	}
+	fs.metadata[key] = value
+	return fs
+}
+
 func (fs *FileSet) String() string {
 
 	var buf bytes.Buffer
@@ -190,6 +228,38 @@ func (fs *FileSet) writeFile(fullPath string, mode os.FileMode, modTime time.Tim
 
 }
 
+// SetIntegrity records precomputed Subresource Integrity values (e.g. "sha384-...") for a file.
+// The file must already exist, will panic otherwise.
+func (fs *FileSet) SetIntegrity(fullPath string, integrity ...string) *FileSet {
+
+	e := fs.findEntry(fullPath)
+	if e == nil || e.IsDir() {
+		panic(fmt.Errorf("no file entry found for %q", fullPath))
+	}
+
+	if e.integrity == nil {
+		e.integrity = make(map[string]string, len(integrity))
+	}
+	for _, v := range integrity {
+		alg := IntegrityAlg(v)
+		if alg == "" {
+			panic(fmt.Errorf("invalid integrity value %q", v))
+		}
+		e.integrity[alg] = v
+	}
+
+	return fs
+}
+
+// Integrity implements IntegrityProvider.
+func (fs *FileSet) Integrity(fullPath string, alg string) string {
+	e := fs.findEntry(fullPath)
+	if e == nil {
+		return ""
+	}
+	return e.integrity[alg]
+}
+
 // Open implements http.FileSystem.
 func (fs *FileSet) Open(fullPath string) (http.File, error) {
 	e := fs.findEntry(fullPath)
@@ -218,13 +288,14 @@ func newFileEntry(name string, b []byte, mode os.FileMode, modTime time.Time, sy
 }
 
 type fileEntry struct {
-	buf      *bytes.Buffer // contents of the file
-	gzipped  bool          // true if buf contains gzipped data
-	name     string        // name component of the file/dir, will never contain a slash except for root
-	mode     os.FileMode
-	modTime  time.Time
-	sys      interface{}
-	children fileEntryList // for directories, the child entries
+	buf       *bytes.Buffer // contents of the file
+	gzipped   bool          // true if buf contains gzipped data
+	name      string        // name component of the file/dir, will never contain a slash except for root
+	mode      os.FileMode
+	modTime   time.Time
+	sys       interface{}
+	children  fileEntryList     // for directories, the child entries
+	integrity map[string]string // precomputed integrity values keyed by algorithm
 }
 
 func (fe *fileEntry) open() (*file, error) {
@@ -0,0 +1,23 @@
+//go:build go1.16
+// +build go1.16
+
+package webresource
+
+import (
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// WriteFSFile creates a file with the contents of name in fsys, e.g. an embed.FS.
+// If name ends in ".gz" the contents are expected to be gzipped, as with WriteGzipFile.
+// Like WriteFile it will panic if the file already exists, its parent does not, or name cannot be read.
+func (fs *FileSet) WriteFSFile(fullPath string, mode os.FileMode, modTime time.Time, fsys iofs.FS, name string) *FileSet {
+	b, err := iofs.ReadFile(fsys, name)
+	if err != nil {
+		panic(fmt.Errorf("error reading %q for %q: %v", name, fullPath, err))
+	}
+	return fs.writeFile(fullPath, mode, modTime, b, strings.HasSuffix(name, ".gz"))
+}
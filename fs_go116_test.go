@@ -0,0 +1,52 @@
+//go:build go1.16
+// +build go1.16
+
+package webresource
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFileSetWriteFSFile(t *testing.T) {
+
+	var gzbuf bytes.Buffer
+	gw := gzip.NewWriter(&gzbuf)
+	gw.Write([]byte("body{}"))
+	gw.Close()
+
+	fsys := fstest.MapFS{
+		"data/a.js":     &fstest.MapFile{Data: []byte("alert(1);")},
+		"data/b.css.gz": &fstest.MapFile{Data: gzbuf.Bytes()},
+	}
+
+	fs := NewFileSet("example.com/embedded").
+		WriteFSFile("/a.js", 0644, time.Unix(1, 0), fsys, "data/a.js").
+		WriteFSFile("/b.css", 0644, time.Unix(1, 0), fsys, "data/b.css.gz")
+
+	for fullPath, expected := range map[string]string{"/a.js": "alert(1);", "/b.css": "body{}"} {
+		f, err := fs.Open(fullPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("%s: expected %q, got %q", fullPath, expected, b)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for missing file")
+		}
+	}()
+	fs.WriteFSFile("/c.js", 0644, time.Unix(1, 0), fsys, "data/c.js")
+}
@@ -0,0 +1,251 @@
+package webresource
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHandlerPrefix is the URL prefix used by Handler when none is specified.
+const DefaultHandlerPrefix = "/_wr/"
+
+// HandlerOptions configures Handler.
+type HandlerOptions struct {
+	Prefix      string // URL prefix modules are mounted under, DefaultHandlerPrefix if empty
+	Fingerprint bool   // URL() returns content-hash fingerprinted URLs, which are served with immutable caching
+	FontOrigin  string // Access-Control-Allow-Origin sent with font files, "*" if empty
+}
+
+// precompressed encodings in order of preference, with the file suffix for each
+var precompressedEncodings = []struct{ encoding, suffix string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// matches a fingerprinted base name, "name.0123456789abcdef.ext"
+var fingerprintedRE = regexp.MustCompile(`^(.+)\.([0-9a-f]{` + strconv.Itoa(fingerprintLen) + `})(\.[^.]+)?$`)
+
+// Handler returns a ModuleHandler which serves the files of each Module in ml under
+// a URL made of the prefix, the Module's Name() and the file's full path, e.g.
+// "/_wr/github.com/x/jquery/jquery.js".
+func Handler(ml ModuleList, opts HandlerOptions) *ModuleHandler {
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultHandlerPrefix
+	}
+	opts.Prefix = "/" + strings.Trim(opts.Prefix, "/") + "/"
+	if opts.Prefix == "//" {
+		opts.Prefix = "/"
+	}
+	return &ModuleHandler{
+		modules: ml,
+		opts:    opts,
+		hashes:  make(map[string]hashEntry),
+	}
+}
+
+// ModuleHandler is an http.Handler which serves the files in a ModuleList.
+// Responses have ETag and Last-Modified set and support conditional and Range requests.
+// Font files are sent with an Access-Control-Allow-Origin header so they can be loaded cross-origin.
+// Sibling files with a ".br" or ".gz" suffix are served instead when the client accepts
+// that encoding.  Directories and anything not found are a 404.
+type ModuleHandler struct {
+	modules ModuleList
+	opts    HandlerOptions
+
+	mu     sync.Mutex
+	hashes map[string]hashEntry // keyed by URL without fingerprint
+}
+
+// hashEntry caches the content hash of a file until its modTime or size changes
+type hashEntry struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// URL returns the URL for the file at fullPath in m.  If fingerprinting is enabled
+// the URL includes the content hash, in which case an error is returned if the file cannot be read.
+func (h *ModuleHandler) URL(m Module, fullPath string) (string, error) {
+	u := h.opts.Prefix + m.Name() + path.Clean("/"+fullPath)
+	if !h.opts.Fingerprint {
+		return u, nil
+	}
+	content, fi, err := readModuleFile(m, fullPath)
+	if err != nil {
+		return "", err
+	}
+	return FingerprintName(u, h.hash(u, fi.ModTime(), content)), nil
+}
+
+// hash returns the hex SHA-256 of content, cached for key
+func (h *ModuleHandler) hash(key string, modTime time.Time, content []byte) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, ok := h.hashes[key]
+	if ok && e.modTime.Equal(modTime) && e.size == int64(len(content)) {
+		return e.hash
+	}
+	sum := sha256.Sum256(content)
+	e = hashEntry{modTime: modTime, size: int64(len(content)), hash: hex.EncodeToString(sum[:])}
+	h.hashes[key] = e
+	return e.hash
+}
+
+// moduleFor finds the Module whose name is the longest prefix of p, returning it and the remaining full path
+func (h *ModuleHandler) moduleFor(p string) (Module, string) {
+	var ret Module
+	var rest string
+	for _, m := range h.modules {
+		name := m.Name()
+		if strings.HasPrefix(p, name+"/") && (ret == nil || len(name) > len(ret.Name())) {
+			ret, rest = m, p[len(name):]
+		}
+	}
+	return ret, rest
+}
+
+// ServeHTTP implements http.Handler.
+func (h *ModuleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, h.opts.Prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, h.opts.Prefix))[1:]
+
+	// strip fingerprint if present
+	var fingerprint string
+	if h.opts.Fingerprint {
+		dir, base := path.Split(p)
+		if subm := fingerprintedRE.FindStringSubmatch(base); subm != nil {
+			p, fingerprint = dir+subm[1]+subm[3], subm[2]
+		}
+	}
+
+	m, fullPath := h.moduleFor(p)
+	if m == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, fi, err := readModuleFile(m, fullPath)
+	if err != nil || fi.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	u := h.opts.Prefix + p
+	hash := h.hash(u, fi.ModTime(), content)
+
+	if fingerprint != "" {
+		if !strings.HasPrefix(hash, fingerprint) {
+			// content changed since the URL was generated, don't serve it as immutable
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if ct := ContentType(fullPath); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	setFontHeaders(w, fullPath, h.opts.FontOrigin)
+
+	// look for a precompressed variant the client accepts
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	etag := hash
+	for _, pe := range precompressedEncodings {
+		if !moduleFileExists(m, fullPath+pe.suffix) {
+			continue
+		}
+		if w.Header().Get("Vary") == "" {
+			w.Header().Set("Vary", "Accept-Encoding")
+		}
+		if !accepted[pe.encoding] {
+			continue
+		}
+		ccontent, _, err := readModuleFile(m, fullPath+pe.suffix)
+		if err != nil {
+			continue
+		}
+		w.Header().Set("Content-Encoding", pe.encoding)
+		content, etag = ccontent, hash+"-"+pe.encoding
+		break
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, fullPath, fi.ModTime(), bytes.NewReader(content))
+}
+
+// readModuleFile reads the contents and info for a file in a Module, directories return no contents
+func readModuleFile(m Module, fullPath string) ([]byte, os.FileInfo, error) {
+	f, err := m.Open(fullPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.IsDir() {
+		return nil, fi, nil
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, fi, nil
+}
+
+// moduleFileExists returns true if fullPath exists in m and is not a directory
+func moduleFileExists(m Module, fullPath string) bool {
+	f, err := m.Open(fullPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	return err == nil && !fi.IsDir()
+}
+
+// acceptedEncodings parses an Accept-Encoding header, encodings with q=0 are excluded
+func acceptedEncodings(header string) map[string]bool {
+	ret := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		enc := strings.ToLower(strings.TrimSpace(fields[0]))
+		if enc == "" {
+			continue
+		}
+		accept := true
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err == nil && q == 0 {
+					accept = false
+				}
+			}
+		}
+		ret[enc] = accept
+	}
+	return ret
+}
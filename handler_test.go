@@ -0,0 +1,106 @@
+package webresource
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+
+	modTime := time.Unix(1500000000, 0)
+
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	gw.Write([]byte(`/* a.js */`))
+	gw.Close()
+
+	a := NewFileSet("example.com/a").
+		Mkdir("/dist", 0755).
+		WriteFile("/dist/a.js", 0644, modTime, []byte(`/* a.js */`)).
+		WriteFile("/dist/a.js.gz", 0644, modTime, gzBuf.Bytes())
+	ab := NewFileSet("example.com/a/b").
+		WriteFile("/b.css", 0644, modTime, []byte(`/* b.css */`))
+
+	h := Handler(ModuleList{a, ab}, HandlerOptions{Fingerprint: true})
+
+	serve := func(method, u string, hdr map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, u, nil)
+		for k, v := range hdr {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// plain URL
+	w := serve("GET", "/_wr/example.com/a/dist/a.js", nil)
+	if w.Code != 200 || w.Body.String() != `/* a.js */` {
+		t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "no-cache" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") && !strings.HasPrefix(w.Header().Get("Content-Type"), "application/javascript") {
+		t.Fatalf("unexpected Content-Type: %s", w.Header().Get("Content-Type"))
+	}
+	etag := w.Header().Get("ETag")
+
+	// conditional request
+	w = serve("GET", "/_wr/example.com/a/dist/a.js", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+
+	// range request
+	w = serve("GET", "/_wr/example.com/a/dist/a.js", map[string]string{"Range": "bytes=3-6"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != `a.js` {
+		t.Fatalf("unexpected range response: %d %q", w.Code, w.Body.String())
+	}
+
+	// precompressed
+	w = serve("GET", "/_wr/example.com/a/dist/a.js", map[string]string{"Accept-Encoding": "br;q=0, gzip"})
+	if w.Header().Get("Content-Encoding") != "gzip" || !bytes.Equal(w.Body.Bytes(), gzBuf.Bytes()) {
+		t.Fatalf("expected precompressed gzip response, got headers: %v", w.Header())
+	}
+	if w.Header().Get("ETag") == etag || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("unexpected headers for precompressed response: %v", w.Header())
+	}
+
+	// nested module names resolve to the longest match
+	w = serve("GET", "/_wr/example.com/a/b/b.css", nil)
+	if w.Code != 200 || w.Body.String() != `/* b.css */` {
+		t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+
+	// fingerprinted URL
+	u, err := h.URL(a, "/dist/a.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u == "/_wr/example.com/a/dist/a.js" || !strings.HasSuffix(u, ".js") {
+		t.Fatalf("URL not fingerprinted: %s", u)
+	}
+	w = serve("GET", u, nil)
+	if w.Code != 200 || w.Header().Get("Cache-Control") != immutableCacheControl {
+		t.Fatalf("unexpected response for fingerprinted URL: %d %v", w.Code, w.Header())
+	}
+	w = serve("GET", "/_wr/example.com/a/dist/a.0000000000000000.js", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for stale fingerprint, got %d", w.Code)
+	}
+
+	// no directory listings or unknown paths
+	for _, p := range []string{"/_wr/example.com/a/dist", "/_wr/example.com/a/", "/_wr/example.com/a/missing.js", "/other/a.js", "/_wr/../x"} {
+		w = serve("GET", p, nil)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for %q, got %d", p, w.Code)
+		}
+	}
+
+}
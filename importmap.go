@@ -0,0 +1,97 @@
+package webresource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ESModuler is an optional interface for Modules which provide ES modules.
+// ESModules returns bare import specifiers mapped to full paths within the Module,
+// e.g. {"lit": "/index.js"}.  A specifier ending in "/" maps a path prefix and its
+// full path must be a directory ending in "/", e.g. {"lit/": "/"}.
+type ESModuler interface {
+	ESModules() map[string]string
+}
+
+// ImportMap is an import map as used with <script type="importmap">.
+type ImportMap struct {
+	Imports map[string]string `json:"imports"`
+}
+
+// NewImportMap builds an ImportMap from the ES modules declared by each Module in ml
+// that implements ESModuler, using h to obtain the URL for each.  Declaring the same
+// specifier in two modules is an error.
+func NewImportMap(ml ModuleList, h *ModuleHandler) (*ImportMap, error) {
+
+	ret := &ImportMap{Imports: make(map[string]string)}
+	from := make(map[string]string)
+
+	for _, m := range ml {
+		esm, ok := m.(ESModuler)
+		if !ok {
+			continue
+		}
+		for spec, fullPath := range esm.ESModules() {
+			if other, ok := from[spec]; ok {
+				return nil, fmt.Errorf("ES module specifier %q declared by both %q and %q", spec, other, m.Name())
+			}
+			from[spec] = m.Name()
+
+			var u string
+			if strings.HasSuffix(spec, "/") {
+				if !strings.HasSuffix(fullPath, "/") {
+					return nil, fmt.Errorf("ES module specifier %q in %q must map to a path ending in \"/\", got %q", spec, m.Name(), fullPath)
+				}
+				// prefixes can't be fingerprinted, the plain URL is used
+				u = h.opts.Prefix + m.Name() + strings.TrimSuffix(path.Clean("/"+fullPath), "/") + "/"
+			} else {
+				var err error
+				u, err = h.URL(m, fullPath)
+				if err != nil {
+					return nil, fmt.Errorf("error with ES module specifier %q in %q: %v", spec, m.Name(), err)
+				}
+			}
+			ret.Imports[spec] = u
+		}
+	}
+
+	return ret, nil
+}
+
+// JSON returns the import map as JSON, keys are sorted.
+func (im *ImportMap) JSON() ([]byte, error) {
+	return json.Marshal(im)
+}
+
+// ScriptTag returns the import map in a <script type="importmap"> tag, with a nonce attribute if nonce is not empty.
+func (im *ImportMap) ScriptTag(nonce string) (template.HTML, error) {
+	b, err := im.JSON()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<script type="importmap"`)
+	if nonce != "" {
+		fmt.Fprintf(&buf, ` nonce="%s"`, template.HTMLEscapeString(nonce))
+	}
+	// json.Marshal escapes <, > and & so the content cannot close the script tag
+	fmt.Fprintf(&buf, ">%s</script>\n", b)
+	return template.HTML(buf.String()), nil
+}
+
+// ServeHTTP implements http.Handler and serves the import map as JSON.
+func (im *ImportMap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := im.JSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/importmap+json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(b)
+}
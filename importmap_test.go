@@ -0,0 +1,54 @@
+package webresource
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+)
+
+func TestImportMap(t *testing.T) {
+
+	lit := NewFileSet("example.com/lit").
+		WriteFile("/index.js", 0644, time.Now(), []byte(`export const lit = 1;`)).
+		Mkdir("/directives", 0755).
+		WriteFile("/directives/class-map.js", 0644, time.Now(), []byte(`export const classMap = 1;`)).
+		SetESModule("lit", "/index.js").
+		SetESModule("lit/", "/")
+	plain := NewFileSet("example.com/plain").
+		WriteFile("/plain.js", 0644, time.Now(), []byte(`/* plain.js */`))
+	ml := Resolve(ModuleList{lit, plain})
+
+	h := Handler(ml, HandlerOptions{})
+	im, err := NewImportMap(ml, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := im.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"imports":{"lit":"/_wr/example.com/lit/index.js","lit/":"/_wr/example.com/lit/"}}` {
+		t.Fatalf("unexpected import map: %s", b)
+	}
+
+	tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(TemplateOptions{Modules: ml, Handler: h})).Parse(`{{wrImportMap "n1"}}`))
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != `<script type="importmap" nonce="n1">`+string(b)+"</script>\n" {
+		t.Fatalf("unexpected template output: %s", buf.String())
+	}
+
+	// duplicate specifiers are an error
+	dup := NewFileSet("example.com/dup").
+		WriteFile("/index.js", 0644, time.Now(), nil).
+		SetESModule("lit", "/index.js")
+	if _, err := NewImportMap(ModuleList{lit, dup}, h); err == nil {
+		t.Fatalf("expected error for duplicate specifier")
+	}
+
+}
@@ -0,0 +1,109 @@
+package webresource
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultIntegrityAlg is the hash algorithm used for Subresource Integrity when none is specified.
+const DefaultIntegrityAlg = "sha384"
+
+// IntegrityProvider is an optional interface a Module can implement to provide precomputed
+// Subresource Integrity values for its files, avoiding hashing at runtime.
+// Integrity returns "" if no value is known for that file and algorithm.
+type IntegrityProvider interface {
+	Integrity(fullPath string, alg string) string
+}
+
+func newIntegrityHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported integrity algorithm %q, must be sha256, sha384 or sha512", alg)
+}
+
+// Integrity returns the Subresource Integrity value (e.g. "sha384-...") for content
+// using alg, which is one of "sha256", "sha384" or "sha512".
+func Integrity(alg string, content []byte) (string, error) {
+	h, err := newIntegrityHash(alg)
+	if err != nil {
+		return "", err
+	}
+	h.Write(content)
+	return alg + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// IntegrityAlg returns the algorithm part of an integrity value, "sha384-..." -> "sha384".
+func IntegrityAlg(integrity string) string {
+	i := strings.Index(integrity, "-")
+	if i < 0 {
+		return ""
+	}
+	return integrity[:i]
+}
+
+// FileIntegrity returns the Subresource Integrity value for the file at fullPath in m.
+// The value from IntegrityProvider is used if m implements it, otherwise the file is hashed.
+func FileIntegrity(m Module, fullPath string, alg string) (string, error) {
+	if ip, ok := m.(IntegrityProvider); ok {
+		if v := ip.Integrity(fullPath, alg); v != "" {
+			return v, nil
+		}
+	}
+	f, err := m.Open(fullPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readerIntegrity(alg, f)
+}
+
+func readerIntegrity(alg string, r io.Reader) (string, error) {
+	h, err := newIntegrityHash(alg)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	return alg + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// ModuleIntegrity returns the Subresource Integrity value for each file in m with
+// one of the extensions given, keyed by full path.
+func ModuleIntegrity(m Module, alg string, exts ...string) (map[string]string, error) {
+	ret := make(map[string]string)
+	ip, _ := m.(IntegrityProvider)
+	for _, ext := range exts {
+		err := Walk(m, ext, func(m Module, fullPath string, f http.File) error {
+			if ip != nil {
+				if v := ip.Integrity(fullPath, alg); v != "" {
+					ret[fullPath] = v
+					return nil
+				}
+			}
+			v, err := readerIntegrity(alg, f)
+			if err != nil {
+				return err
+			}
+			ret[fullPath] = v
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,51 @@
+package webresource
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntegrity(t *testing.T) {
+
+	// values from: printf 'alert("Hello, world.");' | openssl dgst -sha384 -binary | openssl base64 -A
+	content := []byte(`alert("Hello, world.");`)
+	v, err := Integrity("sha384", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "sha384-rwE6Iuo1Y5spnMVUH6Cdjh+wWToU3cZPwiI1th7Wm1MINXGD4PlaByYDRdaBLn0e" {
+		t.Fatalf("unexpected sha384 integrity: %s", v)
+	}
+
+	if _, err := Integrity("md5", content); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	fset := NewFileSet("example.com/a").
+		WriteFile("/a.js", 0644, time.Now(), content).
+		WriteFile("/b.js", 0644, time.Now(), []byte(`/* b.js */`)).
+		SetIntegrity("/b.js", "sha256-precomputed")
+
+	all, err := ModuleIntegrity(fset, "sha256", ".js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a256, _ := Integrity("sha256", content)
+	if all["/a.js"] != a256 {
+		t.Fatalf("unexpected integrity for /a.js: %s", all["/a.js"])
+	}
+	if all["/b.js"] != "sha256-precomputed" {
+		t.Fatalf("precomputed integrity not used for /b.js: %s", all["/b.js"])
+	}
+
+	// algorithms without a precomputed value are hashed
+	b512, err := FileIntegrity(fset, "/b.js", "sha512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := Integrity("sha512", []byte(`/* b.js */`))
+	if b512 != expected {
+		t.Fatalf("unexpected sha512 integrity for /b.js: %s", b512)
+	}
+
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+// webJarPrefix is where a WebJar keeps its files, followed by <name>/<version>/
+const webJarPrefix = "META-INF/resources/webjars/"
+
+// archiveFile is one regular file read from an archive
+type archiveFile struct {
+	name    string // slash separated, relative to the archive root
+	modTime time.Time
+	data    []byte
+}
+
+// readArchive reads an npm tarball (.tgz) or a WebJar or other zip file entirely into memory
+// and returns its files as a FileSet.  For tarballs and plain zips a top level directory
+// shared by all files (e.g. "package/") is removed; for WebJars the root is the
+// webjars/<name>/<version>/ directory and name and version are returned as metadata,
+// together with the Maven coordinates if present.
+func readArchive(fname string) (*webresource.FileSet, map[string]string, error) {
+
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []archiveFile
+	switch {
+	case bytes.HasPrefix(b, []byte{0x1f, 0x8b}):
+		files, err = readTarGz(b)
+	case bytes.HasPrefix(b, []byte("PK")):
+		files, err = readZip(b)
+	default:
+		err = fmt.Errorf("not a gzipped tarball or zip file")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta := make(map[string]string)
+	if root, name, version, ok := webJarRoot(files); ok {
+		meta["name"] = name
+		meta["version"] = version
+		for k, v := range webJarPOM(files) {
+			meta[k] = v
+		}
+		files = underRoot(files, root)
+	} else {
+		files = underRoot(files, commonTopDir(files))
+	}
+
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no files found")
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	fs := webresource.NewFileSet(path.Base(fname))
+	for _, f := range files {
+		fullPath := path.Clean("/" + f.name)
+		if d := path.Dir(fullPath); d != "/" {
+			fs = fs.MkdirAll(d, 0755)
+		}
+		if existing, err := fs.Open(fullPath); err == nil { // duplicate entry, the first one is used
+			existing.Close()
+			continue
+		}
+		fs = fs.WriteFile(fullPath, 0644, f.modTime, f.data)
+	}
+
+	return fs, meta, nil
+}
+
+func readTarGz(b []byte) ([]archiveFile, error) {
+
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	var ret []archiveFile
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, archiveFile{name: cleanArchiveName(hdr.Name), modTime: hdr.ModTime, data: data})
+	}
+	return ret, nil
+}
+
+func readZip(b []byte) ([]archiveFile, error) {
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []archiveFile
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", zf.Name, err)
+		}
+		ret = append(ret, archiveFile{name: cleanArchiveName(zf.Name), modTime: zf.Modified, data: data})
+	}
+	return ret, nil
+}
+
+// cleanArchiveName makes an entry name relative, without any ".." escaping the archive root
+func cleanArchiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.Replace(name, `\`, "/", -1)), "/")
+}
+
+// webJarRoot finds the webjars/<name>/<version>/ directory of a WebJar
+func webJarRoot(files []archiveFile) (root, name, version string, ok bool) {
+	for _, f := range files {
+		if !strings.HasPrefix(f.name, webJarPrefix) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(f.name, webJarPrefix), "/", 3)
+		if len(parts) == 3 {
+			return webJarPrefix + parts[0] + "/" + parts[1] + "/", parts[0], parts[1], true
+		}
+	}
+	return "", "", "", false
+}
+
+// webJarPOM returns the Maven coordinates from a WebJar's pom.properties
+func webJarPOM(files []archiveFile) map[string]string {
+	ret := make(map[string]string)
+	for _, f := range files {
+		if !strings.HasPrefix(f.name, "META-INF/maven/") || path.Base(f.name) != "pom.properties" {
+			continue
+		}
+		sc := bufio.NewScanner(bytes.NewReader(f.data))
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch k := strings.TrimSpace(kv[0]); k {
+			case "groupId", "artifactId":
+				ret["maven."+k] = strings.TrimSpace(kv[1])
+			}
+		}
+		break
+	}
+	return ret
+}
+
+// commonTopDir returns the top level directory all files are in, e.g. "package/", or ""
+func commonTopDir(files []archiveFile) string {
+	top := ""
+	for _, f := range files {
+		i := strings.Index(f.name, "/")
+		if i < 0 {
+			return ""
+		}
+		if top == "" {
+			top = f.name[:i+1]
+		} else if f.name[:i+1] != top {
+			return ""
+		}
+	}
+	return top
+}
+
+// underRoot returns the files in root, with root removed from their names
+func underRoot(files []archiveFile, root string) []archiveFile {
+	if root == "" {
+		return files
+	}
+	var ret []archiveFile
+	for _, f := range files {
+		if strings.HasPrefix(f.name, root) {
+			f.name = strings.TrimPrefix(f.name, root)
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
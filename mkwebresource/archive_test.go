@@ -0,0 +1,279 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+// archiveEntry is a file (or, with a trailing slash, directory) to put in a test archive
+type archiveEntry struct {
+	name, content string
+}
+
+func tgzBytes(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content)), ModTime: time.Unix(1500000000, 0), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(e.name, "/") {
+			hdr.Mode, hdr.Size, hdr.Typeflag = 0755, 0, tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a symlink is skipped
+	if err := tw.WriteHeader(&tar.Header{Name: "package/link.js", Linkname: "index.js", Typeflag: tar.TypeSymlink}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fileSetContents returns every file in fs with its content
+func fileSetContents(t *testing.T, fs *webresource.FileSet) map[string]string {
+	t.Helper()
+	paths, err := listInputFiles(fs, true, &fileFilter{includes: []*regexp.Regexp{regexp.MustCompile(``)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := make(map[string]string)
+	for _, p := range paths {
+		b, err := readInputFile(fs, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret[p] = string(b)
+	}
+	return ret
+}
+
+func TestReadArchive(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name      string
+		content   []byte
+		wantFiles map[string]string
+		wantMeta  map[string]string
+	}{
+		{
+			// npm tarball, "package/" is removed
+			"lib-1.0.0.tgz",
+			tgzBytes(t, []archiveEntry{
+				{"package/", ""},
+				{"package/package.json", `{"name": "lib"}`},
+				{"package/dist/", ""},
+				{"package/dist/lib.js", "lib()"},
+				{"package/../../escape.js", "no escape"},
+			}),
+			nil, // an entry outside "package/" means there is no common top directory
+			nil,
+		},
+		{
+			"lib-1.0.0.tgz",
+			tgzBytes(t, []archiveEntry{
+				{"package/package.json", `{"name": "lib"}`},
+				{"package/dist/lib.js", "lib()"},
+				{"./package/dist/lib.css", "lib{}"},
+				{"package/dist/lib.js", "duplicate, first one wins"},
+			}),
+			map[string]string{"/package.json": `{"name": "lib"}`, "/dist/lib.js": "lib()", "/dist/lib.css": "lib{}"},
+			map[string]string{},
+		},
+		{
+			// WebJar, the root is the webjars/<name>/<version>/ directory
+			"bootstrap-5.3.0.jar",
+			zipBytes(t, []archiveEntry{
+				{"META-INF/MANIFEST.MF", "Manifest-Version: 1.0\n"},
+				{"META-INF/maven/org.webjars.npm/bootstrap/pom.properties", "# generated\ngroupId = org.webjars.npm\nartifactId=bootstrap\nversion=5.3.0\n"},
+				{"META-INF/resources/webjars/bootstrap/5.3.0/", ""},
+				{"META-INF/resources/webjars/bootstrap/5.3.0/dist/css/bootstrap.css", "b{}"},
+				{"META-INF/resources/webjars/bootstrap/5.3.0/package.json", `{"name": "bootstrap"}`},
+			}),
+			map[string]string{"/dist/css/bootstrap.css": "b{}", "/package.json": `{"name": "bootstrap"}`},
+			map[string]string{"name": "bootstrap", "version": "5.3.0", "maven.groupId": "org.webjars.npm", "maven.artifactId": "bootstrap"},
+		},
+		{
+			// plain zip without a common top directory, kept as is
+			"lib.zip",
+			zipBytes(t, []archiveEntry{
+				{"lib.js", "lib()"},
+				{`css\lib.css`, "lib{}"},
+			}),
+			map[string]string{"/lib.js": "lib()", "/css/lib.css": "lib{}"},
+			map[string]string{},
+		},
+	} {
+		fname := filepath.Join(dir, tc.name)
+		if err := ioutil.WriteFile(fname, tc.content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		fs, meta, err := readArchive(fname)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if fs.Name() != tc.name {
+			t.Errorf("%s: got name %q", tc.name, fs.Name())
+		}
+		got := fileSetContents(t, fs)
+		if tc.wantFiles == nil {
+			// no common top directory: "package/" stays and the escaping entry is cleaned into the root
+			if got["/package/dist/lib.js"] != "lib()" || got["/escape.js"] != "no escape" {
+				t.Errorf("%s: unexpected files %v", tc.name, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.wantFiles) {
+			t.Errorf("%s: got files %v, want %v", tc.name, got, tc.wantFiles)
+		}
+		if !reflect.DeepEqual(meta, tc.wantMeta) {
+			t.Errorf("%s: got metadata %v, want %v", tc.name, meta, tc.wantMeta)
+		}
+	}
+
+	for _, tc := range []struct {
+		content []byte
+		wantErr string
+	}{
+		{[]byte("plain text"), "not a gzipped tarball or zip file"},
+		{tgzBytes(t, []archiveEntry{{"package/", ""}}), "no files found"},
+		{[]byte{0x1f, 0x8b, 0}, "EOF"},
+	} {
+		fname := filepath.Join(dir, "bad.tgz")
+		if err := ioutil.WriteFile(fname, tc.content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := readArchive(fname)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%q: expected error containing %q, got %v", tc.content, tc.wantErr, err)
+		}
+	}
+}
+
+func TestCommonTopDir(t *testing.T) {
+
+	files := func(names ...string) []archiveFile {
+		var ret []archiveFile
+		for _, n := range names {
+			ret = append(ret, archiveFile{name: n})
+		}
+		return ret
+	}
+
+	for _, tc := range []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"package/a.js", "package/dist/b.js"}, "package/"},
+		{[]string{"package/a.js", "other/b.js"}, ""},
+		{[]string{"package/a.js", "README.md"}, ""},
+		{[]string{"a.js"}, ""},
+		{nil, ""},
+	} {
+		if got := commonTopDir(files(tc.names...)); got != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.names, got, tc.want)
+		}
+	}
+
+	var names []string
+	for _, f := range underRoot(files("package/a.js", "package/dist/b.js", "other/c.js"), "package/") {
+		names = append(names, f.name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"a.js", "dist/b.js"}) {
+		t.Errorf("underRoot: got %v", names)
+	}
+}
+
+func TestImportArchive(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tgz := tgzBytes(t, []archiveEntry{
+		{"package/package.json", `{"name": "lib", "version": "1.2.3", "license": "MIT", "files": ["dist/"]}`},
+		{"package/dist/js/lib.js", "lib()"},
+		{"package/dist/css/lib.css", "lib{}"},
+		{"package/src/lib.js", "unpublished()"},
+	})
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib-1.2.3.tgz"), tgz, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runTool(t, dir, "import", "-p", "example.com/lib", "-modtime=0", "lib-1.2.3.tgz")
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "webresource-data.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(b)
+
+	// recursive and described by package.json without any flags for it
+	for _, want := range []string{`"/dist/js/lib.js"`, `"/dist/css/lib.css"`, `SetMetadata("version", "1.2.3")`, `SetMetadata("license", "MIT")`} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated source does not contain %s:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "/src/lib.js") {
+		t.Errorf("unpublished file packaged:\n%s", src)
+	}
+
+	out, err = runTool(t, dir, "import", "a.tgz", "b.tgz")
+	if err == nil || !strings.Contains(out, "exactly one archive") {
+		t.Errorf("expected error for two archives, got %v: %s", err, out)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// generated describes what a generated file packages, for comparing two versions of it.
+// File modification times are deliberately not part of it, they depend on the checkout.
+type generated struct {
+	pkg        string            // package clause
+	moduleName string            // name given to NewFileSet
+	hasModule  bool              // whether the Module func is generated
+	mode       string            // "literal" or "embed"
+	files      map[string][]byte // uncompressed contents keyed by full path
+	integrity  map[string]string // space separated SRI values keyed by full path
+	requires   []string          // import paths of required modules, with their alias if any, sorted
+	manifest   []string
+	metadata   map[string]string
+}
+
+// parseGenerated extracts the package and module names, output mode, files, integrity values,
+// requires, manifest and metadata from generated source.
+// readEmbed returns the contents of a file in the embed directory by its //go:embed name.
+func parseGenerated(src []byte, readEmbed func(name string) ([]byte, error)) (*generated, error) {
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &generated{pkg: f.Name.Name, mode: "literal", files: make(map[string][]byte), integrity: make(map[string]string), metadata: make(map[string]string)}
+
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch p {
+		case "embed":
+			ret.mode = "embed"
+			continue
+		case "time", "github.com/gocaveman/webresource":
+			continue
+		}
+		if imp.Name != nil {
+			p = imp.Name.Name + " " + p
+		}
+		ret.requires = append(ret.requires, p)
+	}
+	sort.Strings(ret.requires)
+
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == moduleFuncName {
+			ret.hasModule = true
+		}
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		switch sel.Sel.Name {
+		case "NewFileSet":
+			if len(call.Args) > 0 {
+				ret.moduleName, err = stringLit(call.Args[0])
+				if err != nil {
+					return false
+				}
+			}
+
+		case "WriteFile", "WriteGzipFile":
+			if len(call.Args) != 4 {
+				return true
+			}
+			var fullPath, data string
+			fullPath, err = stringLit(call.Args[0])
+			if err != nil {
+				return false
+			}
+			data, err = stringLit(call.Args[3])
+			if err != nil {
+				return false
+			}
+			b := []byte(data)
+			if sel.Sel.Name == "WriteGzipFile" {
+				b, err = gunzip(b)
+				if err != nil {
+					err = fmt.Errorf("%s: %v", fullPath, err)
+					return false
+				}
+			}
+			ret.files[fullPath] = b
+
+		case "WriteFSFile":
+			if len(call.Args) != 5 {
+				return true
+			}
+			var fullPath, name string
+			fullPath, err = stringLit(call.Args[0])
+			if err != nil {
+				return false
+			}
+			name, err = stringLit(call.Args[4])
+			if err != nil {
+				return false
+			}
+			var b []byte
+			b, err = readEmbed(name)
+			if err == nil && strings.HasSuffix(name, ".gz") {
+				b, err = gunzip(b)
+			}
+			if err != nil {
+				err = fmt.Errorf("%s: %v", fullPath, err)
+				return false
+			}
+			ret.files[fullPath] = b
+
+		case "SetIntegrity":
+			var vals []string
+			for _, arg := range call.Args {
+				var v string
+				v, err = stringLit(arg)
+				if err != nil {
+					return false
+				}
+				vals = append(vals, v)
+			}
+			if len(vals) > 1 {
+				ret.integrity[vals[0]] = strings.Join(vals[1:], " ")
+			}
+
+		case "SetMetadata":
+			if len(call.Args) != 2 {
+				return true
+			}
+			var k, v string
+			k, err = stringLit(call.Args[0])
+			if err != nil {
+				return false
+			}
+			v, err = stringLit(call.Args[1])
+			if err != nil {
+				return false
+			}
+			ret.metadata[k] = v
+
+		case "SetManifest":
+			for _, arg := range call.Args {
+				var p string
+				p, err = stringLit(arg)
+				if err != nil {
+					return false
+				}
+				ret.manifest = append(ret.manifest, p)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// generatedEmbedNames returns the //go:embed names of the files in generated source
+func generatedEmbedNames(src []byte) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "WriteFSFile" && len(call.Args) == 5 {
+			var name string
+			name, err = stringLit(call.Args[4])
+			ret = append(ret, name)
+		}
+		return true
+	})
+	return ret, err
+}
+
+// stringLit returns the value of a string literal, also accepting []byte("...")
+func stringLit(e ast.Expr) (string, error) {
+	if call, ok := e.(*ast.CallExpr); ok && len(call.Args) == 1 {
+		e = call.Args[0]
+	}
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", fmt.Errorf("expected string literal")
+	}
+	return strconv.Unquote(lit.Value)
+}
+
+func gunzip(b []byte) ([]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	return ioutil.ReadAll(gr)
+}
+
+// diffGenerated writes a summary of the differences between the existing and the
+// regenerated output to w, and returns true if there are any.
+func diffGenerated(w io.Writer, existing, regenerated *generated) bool {
+
+	changed := false
+
+	if existing.pkg != regenerated.pkg {
+		fmt.Fprintf(w, "\tpackage changed:  %s -> %s\n", existing.pkg, regenerated.pkg)
+		changed = true
+	}
+	if existing.moduleName != regenerated.moduleName {
+		fmt.Fprintf(w, "\tmodule name changed: %s -> %s\n", orNone(existing.moduleName), orNone(regenerated.moduleName))
+		changed = true
+	}
+	if existing.hasModule != regenerated.hasModule {
+		fmt.Fprintf(w, "\t%s() changed:  %s -> %s\n", moduleFuncName, presence(existing.hasModule), presence(regenerated.hasModule))
+		changed = true
+	}
+
+	if existing.mode != regenerated.mode {
+		fmt.Fprintf(w, "\tmode changed:     %s -> %s\n", existing.mode, regenerated.mode)
+		changed = true
+	}
+
+	var paths []string
+	for p := range existing.files {
+		paths = append(paths, p)
+	}
+	for p := range regenerated.files {
+		if _, ok := existing.files[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		oldb, inOld := existing.files[p]
+		newb, inNew := regenerated.files[p]
+		switch {
+		case !inOld:
+			fmt.Fprintf(w, "\tadded:            %s\n", p)
+		case !inNew:
+			fmt.Fprintf(w, "\tremoved:          %s\n", p)
+		case !bytes.Equal(oldb, newb):
+			fmt.Fprintf(w, "\tcontent changed:  %s\n", p)
+		case existing.integrity[p] != regenerated.integrity[p]:
+			fmt.Fprintf(w, "\tSRI changed:      %s: %s -> %s\n", p, orNone(existing.integrity[p]), orNone(regenerated.integrity[p]))
+		default:
+			continue
+		}
+		changed = true
+	}
+
+	if strings.Join(existing.requires, ",") != strings.Join(regenerated.requires, ",") {
+		fmt.Fprintf(w, "\trequires changed: %s -> %s\n", listOrNone(existing.requires), listOrNone(regenerated.requires))
+		changed = true
+	}
+
+	if strings.Join(existing.manifest, ",") != strings.Join(regenerated.manifest, ",") {
+		fmt.Fprintf(w, "\torder changed:    %s -> %s\n", listOrNone(existing.manifest), listOrNone(regenerated.manifest))
+		changed = true
+	}
+
+	var keys []string
+	for k := range existing.metadata {
+		keys = append(keys, k)
+	}
+	for k := range regenerated.metadata {
+		if _, ok := existing.metadata[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		oldv, inOld := existing.metadata[k]
+		newv, inNew := regenerated.metadata[k]
+		if inOld != inNew || oldv != newv {
+			fmt.Fprintf(w, "\tmetadata changed: %s: %q -> %q\n", k, oldv, newv)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+func listOrNone(l []string) string {
+	return orNone(strings.Join(l, ", "))
+}
+
+func presence(present bool) string {
+	if present {
+		return "generated"
+	}
+	return "not generated"
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+
+	for _, mode := range []string{"literal", "embed"} {
+
+		base := []string{"-p", "example.com/lib", "-modtime=0", "-mode=" + mode}
+
+		dir, err := ioutil.TempDir("", "mkwebresource")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		writeTestFiles(t, dir, map[string]string{"a.js": "a()", "b.css": "b{}"})
+
+		args := func(more ...string) []string {
+			ret := append(append([]string{}, base...), more...)
+			return append(ret, ".")
+		}
+
+		out, err := runTool(t, dir, args()...)
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", base, err, out)
+		}
+
+		// modtimes are not compared
+		out, err = runTool(t, dir, args("-check", "-modtime=1")...)
+		if err != nil {
+			t.Errorf("%v: unexpected -check failure: %v\n%s", base, err, out)
+		}
+
+		for _, tc := range []struct {
+			args    []string
+			wantOut []string
+		}{
+			{[]string{"-sri", "sha256"}, []string{"SRI changed:      /a.js: sha384-", "-> sha256-", "SRI changed:      /b.css"}},
+			{[]string{"-sri", "sha384,sha512"}, []string{"SRI changed:      /a.js: sha384-", " sha512-"}},
+			{[]string{"-sri", ""}, []string{"SRI changed:      /a.js: sha384-", "-> (none)"}},
+			{[]string{"-mode=literal"}, []string{"mode changed:     embed -> literal"}},
+			{[]string{"-mode=embed"}, []string{"mode changed:     literal -> embed"}},
+			{[]string{"-r", "example.com/other"}, []string{"requires changed: (none) -> example.com/other"}},
+			{[]string{"-meta", "license=MIT"}, []string{`metadata changed: license: "" -> "MIT"`}},
+			{[]string{"-e", `\.js$`}, []string{"removed:          /b.css"}},
+			{[]string{"-p", "example.com/renamed"}, []string{"module name changed: example.com/lib -> example.com/renamed", "package changed:  lib -> renamed"}},
+			{[]string{"-p", "example.com/lib;other"}, []string{"package changed:  lib -> other"}},
+			{[]string{"-m=0"}, []string{"Module() changed:  generated -> not generated"}},
+			{[]string{"-r", "example.com/a/ui,example.com/b/ui"}, []string{"requires changed: (none) -> example.com/a/ui, ui2 example.com/b/ui"}},
+		} {
+			if tc.args[0] == "-mode="+mode {
+				continue
+			}
+			out, err := runTool(t, dir, args(append([]string{"-check"}, tc.args...)...)...)
+			if err == nil {
+				t.Errorf("%v %v: -check passed, expected it to report the output out of date", base, tc.args)
+				continue
+			}
+			for _, want := range tc.wantOut {
+				if !strings.Contains(out, want) {
+					t.Errorf("%v %v: -check output does not contain %q:\n%s", base, tc.args, want, out)
+				}
+			}
+		}
+
+		// an alias change alone is reported
+		out, err = runTool(t, dir, args("-r", "example.com/a/ui,example.com/b/ui")...)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		out, err = runTool(t, dir, args("-check", "-r", "example.com/a/ui,example.com/b/ui;bui")...)
+		if err == nil || !strings.Contains(out, "requires changed: example.com/a/ui, ui2 example.com/b/ui -> bui example.com/b/ui, example.com/a/ui") {
+			t.Errorf("%v: expected alias change, got %v:\n%s", base, err, out)
+		}
+
+		os.Remove(filepath.Join(dir, "webresource-data.go"))
+		os.RemoveAll(filepath.Join(dir, "webresource-data"))
+		out, err = runTool(t, dir, args("-check")...)
+		if err == nil || !strings.Contains(out, "does not exist") {
+			t.Errorf("%v: expected missing output error, got %v:\n%s", base, err, out)
+		}
+		out, err = runTool(t, dir, args()...)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, "a.js"), []byte("a2()"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err = runTool(t, dir, args("-check")...)
+		if err == nil || !strings.Contains(out, "content changed:  /a.js") {
+			t.Errorf("%v: expected content change, got %v:\n%s", base, err, out)
+		}
+	}
+}
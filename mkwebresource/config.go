@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gocaveman/webresource"
+)
+
+// configFileNames are looked for in the current directory, at most one may exist
+var configFileNames = []string{"webresource.json", "webresource.toml"}
+
+// config is the content of a webresource.json or webresource.toml file.  Each field
+// corresponds to a flag, flags given on the command line take precedence.
+type config struct {
+	Package   string            `json:"package"`   // -p
+	Requires  []string          `json:"requires"`  // -r
+	Dir       string            `json:"dir"`       // input directory argument, relative to the config file
+	Output    string            `json:"output"`    // -o, relative to the config file
+	Recursive *bool             `json:"recursive"` // -R
+	Module    *bool             `json:"module"`    // -m
+	Filter    string            `json:"filter"`    // -e
+	Include   []string          `json:"include"`   // -include
+	Exclude   []string          `json:"exclude"`   // -exclude
+	Order     []string          `json:"order"`     // inline alternative to -order
+	OrderFile string            `json:"orderFile"` // -order, relative to the config file
+	Mode      string            `json:"mode"`      // -mode
+	EmbedDir  string            `json:"embedDir"`  // -embed-dir
+	Encoding  string            `json:"encoding"`  // "gzip" or "identity", -gzip
+	SRI       []string          `json:"sri"`       // -sri, an empty list means none
+	ModTime   string            `json:"modtime"`   // -modtime
+	Metadata  map[string]string `json:"metadata"`  // -meta
+
+	FromPackageJSON *bool  `json:"fromPackageJSON"` // -from-package-json
+	NPMMap          string `json:"npmMap"`          // -npm-map, relative to the config file
+}
+
+// findConfig returns the name of the config file in dir, "" if there is none.
+func findConfig(dir string) (string, error) {
+	var found []string
+	for _, name := range configFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, filepath.Join(dir, name))
+		}
+	}
+	if len(found) > 1 {
+		return "", fmt.Errorf("found both %s, only one config file is allowed", strings.Join(found, " and "))
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	return found[0], nil
+}
+
+// readConfig reads and validates a config file, JSON or TOML by its extension.
+func readConfig(fname string) (*config, error) {
+
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(fname, ".toml") {
+		v, err := parseTOML(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fname, err)
+		}
+		b, err = json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fname, err)
+		}
+	}
+
+	var cfg config
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fname, jsonErrorMessage(b, err))
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fname, err)
+	}
+
+	// paths are relative to the config file
+	dir := filepath.Dir(fname)
+	for _, p := range []*string{&cfg.Dir, &cfg.Output, &cfg.OrderFile, &cfg.NPMMap} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, filepath.FromSlash(*p))
+		}
+	}
+
+	return &cfg, nil
+}
+
+// jsonErrorMessage rewrites the errors from encoding/json to say where in the file and which field
+func jsonErrorMessage(b []byte, err error) string {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return fmt.Sprintf("line %d: %v", lineAt(b, e.Offset), e)
+	case *json.UnmarshalTypeError:
+		return fmt.Sprintf("line %d: field %q must be %s, not %s", lineAt(b, e.Offset), e.Field, jsonTypeName(e.Type.String()), e.Value)
+	}
+	if strings.HasPrefix(err.Error(), "json: unknown field ") {
+		return fmt.Sprintf("unknown field %s, expected one of: %s", strings.TrimPrefix(err.Error(), "json: unknown field "), strings.Join(configFields(), ", "))
+	}
+	return err.Error()
+}
+
+func jsonTypeName(goType string) string {
+	switch goType {
+	case "[]string":
+		return "a list of strings"
+	case "map[string]string":
+		return "a table of strings"
+	case "bool":
+		return "true or false"
+	}
+	return "a " + goType
+}
+
+func lineAt(b []byte, offset int64) int {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	return bytes.Count(b[:offset], []byte("\n")) + 1
+}
+
+func configFields() []string {
+	var ret []string
+	b, _ := json.Marshal(config{Recursive: new(bool), Module: new(bool), FromPackageJSON: new(bool), Requires: []string{}, Include: []string{}, Exclude: []string{}, Order: []string{}, SRI: []string{}, Metadata: map[string]string{}})
+	var m map[string]interface{}
+	json.Unmarshal(b, &m)
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// validate checks the values which can be checked without looking at the input files.
+func (cfg *config) validate() error {
+
+	if cfg.Package != "" && strings.TrimSpace(cfg.Package) != cfg.Package {
+		return fmt.Errorf("field \"package\" must not have leading or trailing spaces")
+	}
+	for i, r := range cfg.Requires {
+		if strings.TrimSpace(r) == "" || strings.Contains(r, ",") {
+			return fmt.Errorf("field \"requires\" entry %d: %q is not an import path", i+1, r)
+		}
+	}
+	if cfg.Filter != "" {
+		if _, err := regexp.Compile(cfg.Filter); err != nil {
+			return fmt.Errorf("field \"filter\": %v", err)
+		}
+	}
+	if _, err := ruleFlags(cfg.Include).compile(); err != nil {
+		return fmt.Errorf("field \"include\": %v", err)
+	}
+	if _, err := ruleFlags(cfg.Exclude).compile(); err != nil {
+		return fmt.Errorf("field \"exclude\": %v", err)
+	}
+	if len(cfg.Order) > 0 && cfg.OrderFile != "" {
+		return fmt.Errorf("fields \"order\" and \"orderFile\" cannot both be set")
+	}
+	switch cfg.Mode {
+	case "", "literal", "embed":
+	default:
+		return fmt.Errorf("field \"mode\" must be \"literal\" or \"embed\", not %q", cfg.Mode)
+	}
+	if cfg.EmbedDir != "" && cfg.Mode != "embed" {
+		return fmt.Errorf("field \"embedDir\" requires \"mode\": \"embed\"")
+	}
+	switch cfg.Encoding {
+	case "", "gzip", "identity":
+	default:
+		return fmt.Errorf("field \"encoding\" must be \"gzip\" or \"identity\", not %q", cfg.Encoding)
+	}
+	for _, alg := range cfg.SRI {
+		if _, err := webresource.Integrity(alg, nil); err != nil {
+			return fmt.Errorf("field \"sri\": %v", err)
+		}
+	}
+	if cfg.ModTime != "" {
+		switch cfg.ModTime {
+		case "git", "SOURCE_DATE_EPOCH":
+		default:
+			if _, err := modTimeFunc(cfg.ModTime, ""); err != nil {
+				return fmt.Errorf("field \"modtime\": %v", err)
+			}
+		}
+	}
+	for k := range cfg.Metadata {
+		if k == "" {
+			return fmt.Errorf("field \"metadata\" has an empty key")
+		}
+	}
+
+	return nil
+}
+
+// apply sets each flag not given on the command line from the config.
+func (cfg *config) apply(fset *flag.FlagSet) error {
+
+	given := make(map[string]bool)
+	fset.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	set := func(name, value string) {
+		if err == nil && !given[name] {
+			err = fset.Set(name, value)
+		}
+	}
+
+	if cfg.Package != "" {
+		set("p", cfg.Package)
+	}
+	if cfg.Requires != nil {
+		set("r", strings.Join(cfg.Requires, ","))
+	}
+	if cfg.Output != "" {
+		set("o", cfg.Output)
+	}
+	if cfg.Recursive != nil {
+		set("R", strconv.FormatBool(*cfg.Recursive))
+	}
+	if cfg.Module != nil {
+		set("m", strconv.FormatBool(*cfg.Module))
+	}
+	if cfg.Filter != "" {
+		set("e", cfg.Filter)
+	}
+	if !given["include"] {
+		for _, v := range cfg.Include {
+			set("include", v)
+		}
+	}
+	if !given["exclude"] {
+		for _, v := range cfg.Exclude {
+			set("exclude", v)
+		}
+	}
+	if cfg.OrderFile != "" {
+		set("order", cfg.OrderFile)
+	}
+	if cfg.Mode != "" {
+		set("mode", cfg.Mode)
+	}
+	if cfg.EmbedDir != "" {
+		set("embed-dir", cfg.EmbedDir)
+	}
+	if cfg.Encoding != "" {
+		set("gzip", strconv.FormatBool(cfg.Encoding == "gzip"))
+	}
+	if cfg.SRI != nil {
+		set("sri", strings.Join(cfg.SRI, ","))
+	}
+	if cfg.ModTime != "" {
+		set("modtime", cfg.ModTime)
+	}
+	if cfg.FromPackageJSON != nil {
+		set("from-package-json", strconv.FormatBool(*cfg.FromPackageJSON))
+	}
+	if cfg.NPMMap != "" {
+		set("npm-map", cfg.NPMMap)
+	}
+
+	return err
+}
+
+// metaFlags is a repeatable key=value flag
+type metaFlags map[string]string
+
+func (m metaFlags) String() string {
+	var ret []string
+	for k, v := range m {
+		ret = append(ret, k+"="+v)
+	}
+	sort.Strings(ret)
+	return strings.Join(ret, ",")
+}
+
+func (m metaFlags) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	m[v[:i]] = v[i+1:]
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFlagSet declares the flags config.apply sets, with the same names and defaults as main
+func testFlagSet() *flag.FlagSet {
+	fset := flag.NewFlagSet("test", flag.ContinueOnError)
+	fset.String("p", "", "")
+	fset.String("r", "", "")
+	fset.String("o", "./webresource-data.go", "")
+	fset.Bool("R", false, "")
+	fset.Bool("m", true, "")
+	fset.String("e", `\.(js|css)$`, "")
+	fset.Var(&ruleFlags{}, "include", "")
+	fset.Var(&ruleFlags{}, "exclude", "")
+	fset.String("order", "", "")
+	fset.String("mode", "literal", "")
+	fset.String("embed-dir", "", "")
+	fset.Bool("gzip", true, "")
+	fset.String("sri", "sha384", "")
+	fset.String("modtime", "", "")
+	fset.Bool("from-package-json", false, "")
+	fset.String("npm-map", "", "")
+	return fset
+}
+
+func TestConfigApply(t *testing.T) {
+
+	yes, no := true, false
+	cfg := &config{
+		Package:         "example.com/fromconfig",
+		Requires:        []string{"example.com/a", "example.com/b"},
+		Output:          "out.go",
+		Recursive:       &yes,
+		Module:          &no,
+		Filter:          `\.js$`,
+		Include:         []string{"*.js", "*.css"},
+		Exclude:         []string{"*.min.js"},
+		OrderFile:       "order.txt",
+		Mode:            "embed",
+		EmbedDir:        "data",
+		Encoding:        "identity",
+		SRI:             []string{},
+		ModTime:         "git",
+		FromPackageJSON: &yes,
+		NPMMap:          "npm.json",
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want map[string]string
+	}{
+		{nil, map[string]string{
+			"p": "example.com/fromconfig", "r": "example.com/a,example.com/b", "o": "out.go", "R": "true", "m": "false",
+			"e": `\.js$`, "include": "*.js,*.css", "exclude": "*.min.js", "order": "order.txt", "mode": "embed",
+			"embed-dir": "data", "gzip": "false", "sri": "", "modtime": "git", "from-package-json": "true", "npm-map": "npm.json",
+		}},
+		// flags on the command line win, also when set to their default value
+		{[]string{"-p", "example.com/flag", "-R=false", "-gzip=true", "-sri", "sha384", "-include", "*.woff2", "-mode", "literal"}, map[string]string{
+			"p": "example.com/flag", "R": "false", "gzip": "true", "sri": "sha384", "include": "*.woff2", "mode": "literal",
+			"r": "example.com/a,example.com/b", "exclude": "*.min.js",
+		}},
+		// an empty -r on the command line means no requires, not those in the config
+		{[]string{"-r", ""}, map[string]string{"r": ""}},
+	} {
+		fset := testFlagSet()
+		if err := fset.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.apply(fset); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		for name, want := range tc.want {
+			if got := fset.Lookup(name).Value.String(); got != want {
+				t.Errorf("%v: -%s = %q, want %q", tc.args, name, got, want)
+			}
+		}
+	}
+
+	// fields which are not set leave the defaults alone
+	fset := testFlagSet()
+	if err := (&config{}).apply(fset); err != nil {
+		t.Fatal(err)
+	}
+	fset.VisitAll(func(f *flag.Flag) {
+		if f.Value.String() != f.DefValue {
+			t.Errorf("empty config changed -%s to %q", f.Name, f.Value)
+		}
+	})
+}
+
+func TestReadConfig(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFiles(t, dir, map[string]string{
+		"json/webresource.json": `{
+	"package": "example.com/lib",
+	"dir": "dist",
+	"output": "gen/data.go",
+	"recursive": true,
+	"sri": ["sha256"],
+	"metadata": {"license": "MIT"}
+}`,
+		"toml/webresource.toml": `package = "example.com/lib"
+dir = "dist"
+output = "gen/data.go"
+recursive = true
+sri = ["sha256"]
+
+[metadata]
+license = "MIT"
+`,
+	})
+
+	for _, name := range []string{"json/webresource.json", "toml/webresource.toml"} {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		cfg, err := readConfig(fname)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		yes := true
+		want := &config{
+			Package:   "example.com/lib",
+			Dir:       filepath.Join(filepath.Dir(fname), "dist"),
+			Output:    filepath.Join(filepath.Dir(fname), "gen", "data.go"),
+			Recursive: &yes,
+			SRI:       []string{"sha256"},
+			Metadata:  map[string]string{"license": "MIT"},
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s: got %+v, want %+v", name, cfg, want)
+		}
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, tc := range []struct {
+		ext, content, wantErr string
+	}{
+		{".json", "{\n\"package\": \"x\",\n}", "line 3"},
+		{".json", "{\n\n\"recursive\": \"yes\"}", `line 3: field "recursive" must be true or false, not string`},
+		{".json", `{"requires": "a"}`, `field "requires" must be a list of strings`},
+		{".json", `{"packge": "x"}`, `unknown field "packge", expected one of: dir, embedDir,`},
+		{".json", `{"requires": ["a,b"]}`, `field "requires" entry 1: "a,b" is not an import path`},
+		{".json", `{"mode": "inline"}`, `field "mode" must be "literal" or "embed"`},
+		{".json", `{"embedDir": "x"}`, `field "embedDir" requires "mode": "embed"`},
+		{".json", `{"encoding": "br"}`, `field "encoding" must be "gzip" or "identity"`},
+		{".json", `{"sri": ["md5"]}`, `field "sri"`},
+		{".json", `{"modtime": "yesterday"}`, `field "modtime"`},
+		{".json", `{"order": ["a.js"], "orderFile": "order.txt"}`, `cannot both be set`},
+		{".json", `{"include": ["re:("]}`, `field "include"`},
+		{".toml", "module = 1", `field "module" must be true or false, not number`},
+		{".toml", "metadata = \"x\"", `field "metadata" must be a table of strings`},
+		{".toml", "package = \"x\"\npackage = \"y\"", `line 2: "package" is defined more than once`},
+	} {
+		fname := filepath.Join(dir, "config"+strings.Repeat("x", i)+tc.ext)
+		if err := ioutil.WriteFile(fname, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readConfig(fname)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.content, tc.wantErr, err)
+		}
+	}
+
+	writeTestFiles(t, dir, map[string]string{"both/webresource.json": "{}", "both/webresource.toml": ""})
+	if _, err := findConfig(filepath.Join(dir, "both")); err == nil {
+		t.Errorf("expected error for two config files")
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileName is read from the input directory if present, it uses gitignore syntax
+const ignoreFileName = ".webresourceignore"
+
+// ruleFlags is a repeatable flag of include or exclude rules, each either a glob
+// (gitignore style, e.g. "*.min.js", "src/", "lib/**/*.js") or a regular expression
+// prefixed with "re:" matched against the path with a leading slash.
+type ruleFlags []string
+
+func (r *ruleFlags) String() string { return strings.Join(*r, ",") }
+
+func (r *ruleFlags) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
+
+// compile returns a regexp for each rule, all matched against paths with a leading slash
+func (r ruleFlags) compile() ([]*regexp.Regexp, error) {
+	var ret []*regexp.Regexp
+	for _, rule := range r {
+		var re *regexp.Regexp
+		var err error
+		if strings.HasPrefix(rule, "re:") {
+			re, err = regexp.Compile(strings.TrimPrefix(rule, "re:"))
+		} else {
+			re, err = globRegexp(rule)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad rule %q: %v", rule, err)
+		}
+		ret = append(ret, re)
+	}
+	return ret, nil
+}
+
+// ignoreRule is one line of an ignore file
+type ignoreRule struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// readIgnoreFile reads gitignore syntax rules from fname in src, a missing file has no rules.
+func readIgnoreFile(src http.FileSystem, fname string) ([]ignoreRule, error) {
+
+	f, err := src.Open(fname)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []ignoreRule
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`) // escaped leading "#" or "!"
+		rule.re, err = globRegexp(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", fname, lineNo, err)
+		}
+		ret = append(ret, rule)
+	}
+	return ret, sc.Err()
+}
+
+// globRegexp converts a gitignore style pattern to a regexp matching paths with a leading
+// slash.  A pattern without a slash (other than a trailing one) matches at any depth,
+// otherwise it is relative to the input directory; "**" matches any number of directories;
+// "{a,b}" matches either alternative, as in npm's package.json "files"; a trailing slash
+// matches directories only.  A pattern matching a directory matches everything in it.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var buf strings.Builder
+	buf.WriteString("^/")
+	if !anchored {
+		buf.WriteString("(?:.*/)?")
+	}
+
+	body, err := globBody(pattern)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString(body)
+
+	if dirOnly {
+		buf.WriteString("/.*$")
+	} else {
+		buf.WriteString("(?:/.*)?$")
+	}
+
+	return regexp.Compile(buf.String())
+}
+
+// globBody converts the glob syntax in pattern to the equivalent regexp
+func globBody(pattern string) (string, error) {
+
+	var buf strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			buf.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**") && i+2 == len(pattern):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated [ in %q", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += 1 + end
+		case c == '{' && len(braceAlternatives(pattern[i:])) > 1:
+			alts := braceAlternatives(pattern[i:])
+			var res []string
+			n := 1 // the braces
+			for _, alt := range alts {
+				re, err := globBody(alt)
+				if err != nil {
+					return "", err
+				}
+				res = append(res, re)
+				n += len(alt) + 1 // and the commas
+			}
+			buf.WriteString("(?:" + strings.Join(res, "|") + ")")
+			i += n - 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// braceAlternatives returns the comma separated alternatives of the "{...}" group s starts
+// with, nested groups are kept whole.  Fewer than two means s is not a group and "{" is literal.
+func braceAlternatives(s string) []string {
+	var ret []string
+	depth, start := 0, 1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return append(ret, s[start:i])
+			}
+		case ',':
+			if depth == 1 {
+				ret = append(ret, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return nil // unterminated
+}
+
+// unmatchedRules returns those of rules (compiled to res) which match no file in src at all
+func unmatchedRules(src http.FileSystem, rules ruleFlags, res []*regexp.Regexp) ([]string, error) {
+	if len(res) == 0 {
+		return nil, nil
+	}
+	all, err := listInputFiles(src, true, &fileFilter{includes: []*regexp.Regexp{regexp.MustCompile(``)}})
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for i, re := range res {
+		if !matchesAny(re, all) {
+			ret = append(ret, rules[i])
+		}
+	}
+	return ret, nil
+}
+
+func matchesAny(re *regexp.Regexp, paths []string) bool {
+	for _, p := range paths {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// newFileFilter returns the filter for the -e, -include and -exclude flags and the
+// ignore file in src.  The -e regexp is only used if there are no -include rules.
+func newFileFilter(src http.FileSystem, filterExpr string, includes, excludes ruleFlags) (*fileFilter, error) {
+
+	ret := &fileFilter{}
+	var err error
+
+	if len(includes) > 0 {
+		ret.includes, err = includes.compile()
+		if err != nil {
+			return nil, fmt.Errorf("Bad -include: %v", err)
+		}
+	} else {
+		re, err := regexp.Compile(filterExpr)
+		if err != nil {
+			return nil, fmt.Errorf("Bad filter regexp %q: %v", filterExpr, err)
+		}
+		ret.includes = []*regexp.Regexp{re}
+	}
+
+	ret.excludes, err = excludes.compile()
+	if err != nil {
+		return nil, fmt.Errorf("Bad -exclude: %v", err)
+	}
+
+	ret.ignore, err = readIgnoreFile(src, "/"+ignoreFileName)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %v", ignoreFileName, err)
+	}
+
+	return ret, nil
+}
+
+// fileFilter decides which files in the input directory are packaged
+type fileFilter struct {
+	includes []*regexp.Regexp // a file must match at least one
+	excludes []*regexp.Regexp // and none of these
+	ignore   []ignoreRule     // and not be ignored, the last matching rule wins
+	only     []*regexp.Regexp // and if set, match at least one of these (e.g. the files a package.json publishes)
+	outputs  []string         // generated paths (output file, embed directory) which are never inputs
+}
+
+// includeFile returns true if the file at p (relative to the input dir, leading slash) is packaged
+func (ff *fileFilter) includeFile(p string) bool {
+	if ff.excluded(p) {
+		return false
+	}
+	if len(ff.only) > 0 && !anyMatch(ff.only, p) {
+		return false
+	}
+	return anyMatch(ff.includes, p)
+}
+
+func anyMatch(res []*regexp.Regexp, p string) bool {
+	for _, re := range res {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipDir returns true if nothing in the directory at p can be packaged
+func (ff *fileFilter) skipDir(p string) bool {
+	return ff.excluded(strings.TrimSuffix(p, "/") + "/")
+}
+
+func (ff *fileFilter) excluded(p string) bool {
+	for _, o := range ff.outputs {
+		if strings.TrimSuffix(p, "/") == o {
+			return true
+		}
+	}
+	for _, re := range ff.excludes {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	ignored := false
+	for _, rule := range ff.ignore {
+		if rule.re.MatchString(p) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+func TestGlobRegexp(t *testing.T) {
+
+	for _, tc := range []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		// unanchored, matches at any depth
+		{"*.js", []string{"/a.js", "/x/y/a.js"}, []string{"/a.css", "/a.json"}},
+		{"a.min.js", []string{"/a.min.js", "/x/a.min.js"}, []string{"/aXminXjs"}},
+		{"node_modules", []string{"/node_modules", "/node_modules/x.js", "/x/node_modules/y.js"}, []string{"/node_modules2/x.js"}},
+		// anchored by a slash
+		{"/a.js", []string{"/a.js"}, []string{"/x/a.js"}},
+		{"src/*.js", []string{"/src/a.js"}, []string{"/x/src/a.js", "/src/x/a.js"}},
+		// "**"
+		{"lib/**/*.js", []string{"/lib/a.js", "/lib/x/a.js", "/lib/x/y/a.js"}, []string{"/a.js", "/x/lib/a.js", "/lib/a.css"}},
+		{"**/dist", []string{"/dist", "/dist/a.js", "/x/y/dist/a.js"}, []string{"/distx/a.js"}},
+		{"lib/**", []string{"/lib/a.js", "/lib/x/a.js"}, []string{"/lib", "/x/lib/a.js"}},
+		// trailing slash, directories only
+		{"src/", []string{"/src/", "/src/a.js", "/x/src/a.js"}, []string{"/src", "/srcx/a.js"}},
+		{"/dist/", []string{"/dist/a.js"}, []string{"/x/dist/a.js", "/dist"}},
+		// character classes and escapes
+		{"a?.js", []string{"/ab.js"}, []string{"/a.js", "/a/.js"}},
+		{"[ab].js", []string{"/a.js", "/b.js"}, []string{"/c.js"}},
+		{"[!ab].js", []string{"/c.js"}, []string{"/a.js"}},
+		{`\*.js`, []string{"/*.js"}, []string{"/a.js"}},
+		// brace alternatives, as in bootstrap's package.json
+		{"dist/{css,js}/*.{css,js,map}", []string{"/dist/css/a.css", "/dist/js/bootstrap.bundle.js", "/dist/js/a.js.map"}, []string{"/dist/scss/a.css", "/dist/css/a.txt", "/x/dist/js/a.js"}},
+		{"{a,{b,c}*}.js", []string{"/a.js", "/b.js", "/cx.js"}, []string{"/ax.js", "/d.js"}},
+		{"lib/{**/,}*.js", []string{"/lib/a.js", "/lib/x/y/a.js"}, []string{"/a.js"}},
+		// not a group, "{" is literal
+		{"{js}.x", []string{"/{js}.x"}, []string{"/js.x"}},
+		{"a{b,c", []string{"/a{b,c"}, []string{"/ab"}},
+	} {
+		re, err := globRegexp(tc.pattern)
+		if err != nil {
+			t.Errorf("%q: %v", tc.pattern, err)
+			continue
+		}
+		for _, p := range tc.match {
+			if !re.MatchString(p) {
+				t.Errorf("%q (%s) should match %q", tc.pattern, re, p)
+			}
+		}
+		for _, p := range tc.noMatch {
+			if re.MatchString(p) {
+				t.Errorf("%q (%s) should not match %q", tc.pattern, re, p)
+			}
+		}
+	}
+
+	for _, pattern := range []string{"", "/", "[abc"} {
+		if _, err := globRegexp(pattern); err == nil {
+			t.Errorf("%q: expected error", pattern)
+		}
+	}
+}
+
+func TestFileFilter(t *testing.T) {
+
+	src := webresource.NewFileSet("test").
+		WriteFile("/"+ignoreFileName, 0644, time.Time{}, []byte(`# comment
+*.min.js
+!keep.min.js
+vendor/
+\!bang.js
+`))
+
+	ff, err := newFileFilter(src, `\.js$`, nil, ruleFlags{"re:^/skip/", "test/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[string]bool{
+		"/a.js":          true,
+		"/a.css":         false,
+		"/a.min.js":      false,
+		"/x/keep.min.js": true, // negated, the last matching rule wins
+		"/vendor/a.js":   false,
+		"/x/vendor/a.js": false,
+		"/!bang.js":      false,
+		"/bang.js":       true,
+		"/skip/a.js":     false,
+		"/x/skip/a.js":   true,
+		"/x/test/a.js":   false,
+	} {
+		if got := ff.includeFile(p); got != want {
+			t.Errorf("includeFile(%q) = %v, want %v", p, got, want)
+		}
+	}
+
+	if !ff.skipDir("/vendor") || !ff.skipDir("/x/test") || ff.skipDir("/x") {
+		t.Errorf("wrong skipDir results")
+	}
+
+	// -include replaces the -e regexp, the files a package publishes further limit it
+	ff, err = newFileFilter(src, `\.js$`, ruleFlags{"*.css", "*.js"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff.only, err = ruleFlags{"/dist/"}.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]bool{
+		"/dist/a.css":     true,
+		"/dist/a.js":      true,
+		"/dist/a.min.js":  false,
+		"/src/a.js":       false,
+		"/dist/README.md": false,
+	} {
+		if got := ff.includeFile(p); got != want {
+			t.Errorf("includeFile(%q) with -include = %v, want %v", p, got, want)
+		}
+	}
+
+	if _, err := newFileFilter(src, `\.js$`, ruleFlags{"re:("}, nil); err == nil {
+		t.Errorf("expected error for bad -include")
+	}
+}
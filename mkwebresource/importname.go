@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+	"unicode"
+)
+
+// reservedNames are declared at package level in the generated file, imports must not use them
+var reservedNames = []string{"webresource", "time", "embed", "embedded", "requires", "addFiles", moduleFuncName}
+
+// splitImport splits an import path with an optional ";name" suffix naming the Go package
+// explicitly, e.g. "github.com/x/go-ui;ui".
+func splitImport(s string) (importPath, name string) {
+	if i := strings.Index(s, ";"); i >= 0 {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	return strings.TrimSpace(s), ""
+}
+
+// packageName returns the import path and Go package name for a -p value, the name
+// being derived from the path unless given explicitly.
+func packageName(s string) (importPath, name string, err error) {
+	importPath, name = splitImport(s)
+	if importPath == "" {
+		return "", "", fmt.Errorf("empty import path in %q", s)
+	}
+	if name == "" {
+		return importPath, identifier(importLocalName(trimMajorSemver(importPath))), nil
+	}
+	if !token.IsIdentifier(name) || name == "_" {
+		return "", "", fmt.Errorf("%q is not a valid package name", name)
+	}
+	return importPath, name, nil
+}
+
+// identifier turns a name derived from an import path into a valid Go identifier,
+// dropping characters which cannot appear in one and prefixing names which start
+// with a digit or are keywords with "pkg".
+func identifier(name string) string {
+	ret := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, name)
+	if !token.IsIdentifier(ret) || ret == "_" {
+		ret = "pkg" + ret
+	}
+	return ret
+}
+
+// requireImport is a required package as imported by the generated file
+type requireImport struct {
+	path  string
+	name  string // local name in the generated file
+	alias bool   // name differs from the default, so the import must say it
+}
+
+// requireImports assigns each required package a distinct local name.  Explicitly named
+// requires ("path;name") keep their name, others use the name derived from their path,
+// made a valid identifier and numbered from 2 if already taken (e.g. "ui2").
+func requireImports(requireList []string) ([]requireImport, error) {
+
+	taken := make(map[string]string) // local name -> import path, "" for reserved
+	for _, n := range reservedNames {
+		taken[n] = ""
+	}
+
+	var ret []requireImport
+	seen := make(map[string]bool)
+	for _, r := range requireList {
+		p, name := splitImport(r)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if name != "" {
+			if !token.IsIdentifier(name) || name == "_" {
+				return nil, fmt.Errorf("%q is not a valid package name for %q", name, p)
+			}
+			if other, ok := taken[name]; ok {
+				if other == "" {
+					return nil, fmt.Errorf("package name %q for %q is used by the generated code", name, p)
+				}
+				return nil, fmt.Errorf("package name %q given for both %q and %q", name, other, p)
+			}
+			taken[name] = p
+		}
+		ret = append(ret, requireImport{path: p, name: name})
+	}
+
+	for i := range ret {
+		ri := &ret[i]
+		if ri.name == "" {
+			base := identifier(importLocalName(trimMajorSemver(ri.path)))
+			ri.name = base
+			for n := 2; ; n++ {
+				if _, ok := taken[ri.name]; !ok {
+					break
+				}
+				ri.name = fmt.Sprintf("%s%d", base, n)
+			}
+			taken[ri.name] = ri.path
+		}
+		ri.alias = ri.name != importLocalName(trimMajorSemver(ri.path))
+	}
+
+	return ret, nil
+}
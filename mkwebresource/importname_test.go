@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackageName(t *testing.T) {
+
+	for _, tc := range []struct {
+		in, path, name string
+	}{
+		{"github.com/gocaveman-libs/jquery", "github.com/gocaveman-libs/jquery", "jquery"},
+		{"github.com/gocaveman-libs/jquery/v3", "github.com/gocaveman-libs/jquery/v3", "jquery"},
+		{"example.com/jquery.ui-theme", "example.com/jquery.ui-theme", "jqueryuitheme"},
+		{"example.com/3d", "example.com/3d", "pkg3d"},
+		{"example.com/go", "example.com/go", "pkggo"},
+		{"example.com/+-+", "example.com/+-+", "pkg"},
+		{"example.com/go-ui;ui", "example.com/go-ui", "ui"},
+		{" example.com/go-ui ; ui ", "example.com/go-ui", "ui"},
+		{"example.com/x/v2;x2", "example.com/x/v2", "x2"},
+	} {
+		p, name, err := packageName(tc.in)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if p != tc.path || name != tc.name {
+			t.Errorf("%q: got %q, %q, want %q, %q", tc.in, p, name, tc.path, tc.name)
+		}
+	}
+
+	for _, in := range []string{"", ";x", "example.com/x;func", "example.com/x;_", "example.com/x;1x", "example.com/x;a-b"} {
+		if _, _, err := packageName(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestRequireImports(t *testing.T) {
+
+	for _, tc := range []struct {
+		in   []string
+		want []requireImport
+	}{
+		{nil, nil},
+		{[]string{"github.com/x/jquery", "github.com/x/bootstrap/v4"}, []requireImport{
+			{"github.com/x/jquery", "jquery", false},
+			{"github.com/x/bootstrap/v4", "bootstrap", false},
+		}},
+		// collisions are numbered in sequence
+		{[]string{"github.com/a/ui", "github.com/b/ui", "github.com/c/ui"}, []requireImport{
+			{"github.com/a/ui", "ui", false},
+			{"github.com/b/ui", "ui2", true},
+			{"github.com/c/ui", "ui3", true},
+		}},
+		{[]string{"example.com/a/x/v2", "example.com/b/x/v2"}, []requireImport{
+			{"example.com/a/x/v2", "x", false},
+			{"example.com/b/x/v2", "x2", true},
+		}},
+		// "ui2" is taken by the explicitly named require, wherever it comes in the list
+		{[]string{"github.com/a/ui", "github.com/b/ui", "github.com/c/other;ui2"}, []requireImport{
+			{"github.com/a/ui", "ui", false},
+			{"github.com/b/ui", "ui3", true},
+			{"github.com/c/other", "ui2", true},
+		}},
+		// names which are not identifiers
+		{[]string{"example.com/3d", "example.com/go", "example.com/range", "example.com/go-ui"}, []requireImport{
+			{"example.com/3d", "pkg3d", true},
+			{"example.com/go", "pkggo", true},
+			{"example.com/range", "pkgrange", true},
+			{"example.com/go-ui", "goui", false},
+		}},
+		// names used by the generated code
+		{[]string{"example.com/webresource", "example.com/time", "example.com/embed", "example.com/requires"}, []requireImport{
+			{"example.com/webresource", "webresource2", true},
+			{"example.com/time", "time2", true},
+			{"example.com/embed", "embed2", true},
+			{"example.com/requires", "requires2", true},
+		}},
+		// an explicit name equal to the default needs no alias
+		{[]string{"example.com/go-ui;goui", "example.com/ui;ui"}, []requireImport{
+			{"example.com/go-ui", "goui", false},
+			{"example.com/ui", "ui", false},
+		}},
+		// duplicates and empty entries are dropped
+		{[]string{"example.com/ui", "", "example.com/ui;ui", " example.com/ui "}, []requireImport{
+			{"example.com/ui", "ui", false},
+		}},
+	} {
+		got, err := requireImports(tc.in)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q:\ngot  %+v\nwant %+v", tc.in, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		in      []string
+		wantErr string
+	}{
+		{[]string{"example.com/a;ui", "example.com/b;ui"}, `package name "ui" given for both "example.com/a" and "example.com/b"`},
+		{[]string{"example.com/a;time"}, `package name "time" for "example.com/a" is used by the generated code`},
+		{[]string{"example.com/a;Module"}, `is used by the generated code`},
+		{[]string{"example.com/a;type"}, `"type" is not a valid package name`},
+		{[]string{"example.com/a;2a"}, `"2a" is not a valid package name`},
+	} {
+		_, err := requireImports(tc.in)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%q: expected error containing %q, got %v", tc.in, tc.wantErr, err)
+		}
+	}
+}
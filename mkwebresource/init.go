@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// runInit implements "mkwebresource init", which creates the skeleton of a package
+// wrapping a browser library: doc.go with the //go:generate directive, a test that
+// the Module resolves and every file opens, a README stub and an initial generated file.
+func runInit(args []string) {
+
+	fset := flag.NewFlagSet("init", flag.ExitOnError)
+	importName := fset.String("p", "", "Full package import path, required, include semver major number if applicable (e.g. \"pkg/v2\"), append \";name\" to name the Go package explicitly (e.g. \"pkg/go-ui;ui\")")
+	requires := fset.String("r", "", "List of full package import paths to require for this module, comma separated, each optionally followed by \";name\" to import it under that name")
+	fset.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: mkwebresource init -p <import path> [-r <requires>] [directory]\n\nThe directory defaults to the last element of the import path and must not contain the files to be created.\n\n")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+
+	*importName = strings.TrimSpace(*importName)
+	if *importName == "" {
+		fmt.Fprintf(os.Stderr, "You must provide an import name with -p\n")
+		os.Exit(1)
+	}
+	importPath, pkgName, err := packageName(*importName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad -p value: %v\n", err)
+		os.Exit(1)
+	}
+	requireList := splitRequires(*requires)
+	imports, err := requireImports(requireList)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad -r value: %v\n", err)
+		os.Exit(1)
+	}
+	var requirePaths []string
+	for _, ri := range imports {
+		requirePaths = append(requirePaths, ri.path)
+	}
+
+	dir := filepath.Base(trimMajorSemver(importPath))
+	switch fset.NArg() {
+	case 0:
+	case 1:
+		dir = fset.Arg(0)
+	default:
+		fset.Usage()
+		os.Exit(1)
+	}
+
+	data := initData{
+		ImportPath: importPath,
+		Package:    pkgName,
+		Requires:   requirePaths,
+		Generate:   generateDirective(*importName, requireList),
+	}
+
+	files := []struct {
+		name   string
+		tmpl   *template.Template
+		goCode bool
+	}{
+		{"doc.go", initDocTmpl, true},
+		{"webresource_test.go", initTestTmpl, true},
+		{"README.md", initReadmeTmpl, false},
+	}
+
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(dir, f.name)); err == nil {
+			fmt.Fprintf(os.Stderr, "%s already exists, not overwriting anything\n", filepath.Join(dir, f.name))
+			os.Exit(1)
+		}
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating directory %q: %v\n", dir, err)
+		os.Exit(1)
+	}
+
+	for _, f := range files {
+		var buf bytes.Buffer
+		err := f.tmpl.Execute(&buf, data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating %s: %v\n", f.name, err)
+			os.Exit(1)
+		}
+		b := buf.Bytes()
+		if f.goCode {
+			b, err = format.Source(b)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error during gofmt of %s: %v\n", f.name, err)
+				os.Exit(1)
+			}
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, f.name), b, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", f.name, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Created %s\n", filepath.Join(dir, f.name))
+	}
+
+	// run the generator once so the package compiles before any files are added
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding mkwebresource executable: %v\n", err)
+		os.Exit(1)
+	}
+	cmd := exec.Command(self, generateArgs(*importName, requireList)...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running mkwebresource in %q: %v\n", dir, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Created %s\n", filepath.Join(dir, "webresource-data.go"))
+	fmt.Fprintf(os.Stderr, "Copy the library's files into %s and run go generate there.\n", dir)
+}
+
+type initData struct {
+	ImportPath string
+	Package    string
+	Requires   []string
+	Generate   string
+}
+
+// generateArgs returns the mkwebresource arguments used in the //go:generate directive
+func generateArgs(importName string, requireList []string) []string {
+	ret := []string{"-p", importName}
+	if len(requireList) > 0 {
+		ret = append(ret, "-r", strings.Join(requireList, ","))
+	}
+	return append(ret, "-R", ".")
+}
+
+func generateDirective(importName string, requireList []string) string {
+	args := generateArgs(importName, requireList)
+	for i, a := range args {
+		if strings.HasPrefix(a, "-") || a == "." {
+			continue
+		}
+		args[i] = fmt.Sprintf("%q", a)
+	}
+	return "//go:generate mkwebresource " + strings.Join(args, " ")
+}
+
+// splitRequires splits a comma separated list of import paths, dropping empty entries
+func splitRequires(requires string) []string {
+	var ret []string
+	for _, r := range strings.Split(requires, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+var initDocTmpl = template.Must(template.New("doc.go").Parse(`// Package {{.Package}} provides a browser library as a webresource Module,
+// see github.com/gocaveman/webresource.  Module() returns it.
+package {{.Package}}
+
+{{.Generate}}
+`))
+
+var initTestTmpl = template.Must(template.New("webresource_test.go").Parse(`package {{.Package}}
+
+import (
+	"io"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/gocaveman/webresource"
+)
+
+func TestModule(t *testing.T) {
+
+	m := Module()
+	if m.Name() != {{printf "%q" .ImportPath}} {
+		t.Fatalf("unexpected module name %q", m.Name())
+	}
+
+	// the module resolves, after everything it requires
+	ml := webresource.Resolve(webresource.ModuleList{m})
+	if len(ml) == 0 || ml[len(ml)-1] != m {
+		t.Fatalf("module does not resolve last: %v", ml)
+	}
+	resolved := make(map[string]bool, len(ml))
+	for _, rm := range ml {
+		resolved[rm.Name()] = true
+	}
+	for _, r := range m.Requires() {
+		rm, ok := r.(webresource.Module)
+		if !ok {
+			t.Fatalf("required %v is not a Module", r)
+		}
+		if !resolved[rm.Name()] {
+			t.Errorf("required module %q not resolved", rm.Name())
+		}
+	}
+
+	// every file opens and reads
+	var walk func(dir string)
+	walk = func(dir string) {
+		f, err := m.Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		fis, err := f.Readdir(-1)
+		if err != nil && err != io.EOF { // an empty directory gives io.EOF
+			t.Fatalf("readdir %s: %v", dir, err)
+		}
+		for _, fi := range fis {
+			p := path.Join(dir, fi.Name())
+			if fi.IsDir() {
+				walk(p)
+				continue
+			}
+			ff, err := m.Open(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ioutil.ReadAll(ff)
+			ff.Close()
+			if err != nil {
+				t.Errorf("read %s: %v", p, err)
+			}
+		}
+	}
+	walk("/")
+}
+`))
+
+var initReadmeTmpl = template.Must(template.New("README.md").Parse(`# {{.Package}}
+
+A browser library packaged as a Go webresource module, see https://github.com/gocaveman/webresource.
+
+` + "```" + `
+import "{{.ImportPath}}"
+
+modList := webresource.Resolve(webresource.ModuleList{ {{.Package}}.Module() })
+` + "```" + `
+{{if .Requires}}
+Requires:
+{{range .Requires}}
+- {{.}}{{end}}
+{{end}}
+## Updating
+
+Copy the library's files into this directory and run ` + "`go generate`" + `, which runs:
+
+` + "```" + `
+{{.Generate}}
+` + "```" + `
+`))
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateDirective(t *testing.T) {
+
+	for _, tc := range []struct {
+		importName string
+		requires   []string
+		want       string
+	}{
+		{"example.com/lib", nil, `//go:generate mkwebresource -p "example.com/lib" -R .`},
+		{"example.com/go-ui;ui", []string{"example.com/a", "example.com/b/ui;bui"},
+			`//go:generate mkwebresource -p "example.com/go-ui;ui" -r "example.com/a,example.com/b/ui;bui" -R .`},
+	} {
+		if got := generateDirective(tc.importName, tc.requires); got != tc.want {
+			t.Errorf("%q %q: got %s, want %s", tc.importName, tc.requires, got, tc.want)
+		}
+	}
+}
+
+// directiveArgs returns the mkwebresource arguments of the //go:generate directive in the
+// Go file fname, unquoted the way go generate does
+func directiveArgs(t *testing.T, fname string) []string {
+	t.Helper()
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if !strings.HasPrefix(line, "//go:generate mkwebresource ") {
+			continue
+		}
+		args := strings.Fields(strings.TrimPrefix(line, "//go:generate mkwebresource "))
+		for i, a := range args {
+			if strings.HasPrefix(a, `"`) {
+				if args[i], err = strconv.Unquote(a); err != nil {
+					t.Fatalf("%s: %v", line, err)
+				}
+			}
+		}
+		return args
+	}
+	t.Fatalf("no //go:generate directive in %s", fname)
+	return nil
+}
+
+func TestInit(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out, err := runTool(t, dir, "init", "-r", "example.com/a")
+	if err == nil || !strings.Contains(out, "You must provide an import name with -p") {
+		t.Errorf("expected error without -p, got %v: %s", err, out)
+	}
+
+	out, err = runTool(t, dir, "init", "-p", "example.com/go-ui;ui", "-r", "example.com/a,example.com/b/ui;bui", "lib")
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	for _, name := range []string{"doc.go", "webresource_test.go", "README.md", "webresource-data.go"} {
+		if _, err := os.Stat(filepath.Join(dir, "lib", name)); err != nil {
+			t.Errorf("%s not created: %v", name, err)
+		}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "lib", "webresource-data.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "package ui\n") || !strings.Contains(string(b), `bui "example.com/b/ui"`) {
+		t.Errorf("generated file does not use the package name and alias given:\n%s", b)
+	}
+
+	// nothing is overwritten, also when only one of the files exists
+	readme := filepath.Join(dir, "lib", "README.md")
+	if err := ioutil.WriteFile(readme, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runTool(t, dir, "init", "-p", "example.com/go-ui;ui", "lib")
+	if err == nil || !strings.Contains(out, "already exists, not overwriting anything") {
+		t.Errorf("expected refusal to overwrite, got %v: %s", err, out)
+	}
+	if b, _ := ioutil.ReadFile(readme); string(b) != "edited" {
+		t.Errorf("README.md overwritten: %s", b)
+	}
+	writeTestFiles(t, dir, map[string]string{"other/README.md": "mine"})
+	out, err = runTool(t, dir, "init", "-p", "example.com/other")
+	if err == nil || !strings.Contains(out, "already exists") {
+		t.Errorf("expected refusal to overwrite, got %v: %s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other", "doc.go")); err == nil {
+		t.Errorf("doc.go created although README.md exists")
+	}
+}
+
+// TestInitCompiles runs the scaffold through init, go test, go generate and go test
+// again in a temporary GOPATH which links to this repository.
+func TestInitCompiles(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("skipping go test of the generated package in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	repo, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gopath, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+
+	link := filepath.Join(gopath, "src", "github.com", "gocaveman", "webresource")
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(repo, link); err != nil {
+		t.Skipf("cannot link the repository into a GOPATH: %v", err)
+	}
+	src := filepath.Join(gopath, "src", "example.com")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	goTest := func(dir string) {
+		t.Helper()
+		cmd := exec.Command(goTool, "test", ".")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOPATH="+gopath, "GO111MODULE=off", "GOFLAGS=")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("go test in %s: %v\n%s", dir, err, out)
+		}
+	}
+
+	// base is required by lib, which is imported under its own package name
+	for _, args := range [][]string{
+		{"init", "-p", "example.com/base"},
+		{"init", "-p", "example.com/go-lib;lib", "-r", "example.com/base"},
+	} {
+		out, err := runTool(t, src, args...)
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+	}
+
+	for _, name := range []string{"base", "go-lib"} {
+		dir := filepath.Join(src, name)
+		goTest(dir)
+
+		// add a file and regenerate as go generate would
+		writeTestFiles(t, dir, map[string]string{"dist/" + name + ".js": name + "()"})
+		out, err := runTool(t, dir, directiveArgs(t, filepath.Join(dir, "doc.go"))...)
+		if err != nil {
+			t.Fatalf("%s: go generate: %v\n%s", name, err, out)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "webresource-data.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), `"/dist/`+name+`.js"`) {
+			t.Errorf("%s: added file not generated:\n%s", name, b)
+		}
+		goTest(dir)
+	}
+}
@@ -8,32 +8,134 @@ import (
 	"go/format"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/gocaveman/webresource"
 )
 
 const moduleFuncName = "Module"
 
 func main() {
 
-	importName := flag.String("p", "", "Full package import path, required, include semver major number if applicable (e.g. \"pkg/v2\")")
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		runInit(os.Args[2:])
+		return
+	}
+
+	importName := flag.String("p", "", "Full package import path, required, include semver major number if applicable (e.g. \"pkg/v2\"), append \";name\" to name the Go package explicitly (e.g. \"pkg/go-ui;ui\")")
 	outputFile := flag.String("o", "./webresource-data.go", "Output file name")
-	filterExpr := flag.String("e", "\\.(js|css)$", "Filter file paths using regular expression")
-	requires := flag.String("r", "", "List of full package import paths to require for this module, comma separated, empty means no requires")
+	filterExpr := flag.String("e", "\\.(js|css|woff2?|ttf|otf|eot)$", "Filter file paths using regular expression, used when no -include is given")
+	var includes, excludes ruleFlags
+	flag.Var(&includes, "include", "Include files matching a glob (gitignore style, e.g. \"*.js\") or \"re:\" prefixed regular expression, may be repeated")
+	flag.Var(&excludes, "exclude", "Exclude files matching a glob (gitignore style, e.g. \"*.min.js\", \"node_modules/\") or \"re:\" prefixed regular expression, may be repeated")
+	list := flag.Bool("list", false, "Print the paths of the files which would be packaged, in sequence, and exit without writing anything")
+	requires := flag.String("r", "", "List of full package import paths to require for this module, comma separated, each optionally followed by \";name\" to import it under that name, empty means no requires")
 	recursive := flag.Bool("R", false, "Enable recursion when scanning the directory, reproduces subdir tree in output")
 	module := flag.Bool("m", true, "Set to 0 to disable the public Module() function definition, in case you want to make your own")
-	flag.Parse()
+	sriAlgs := flag.String("sri", webresource.DefaultIntegrityAlg, "List of Subresource Integrity hash algorithms (sha256, sha384, sha512) to precompute for each file, comma separated, empty means none")
+	orderFile := flag.String("order", "", "File listing paths (one per line, relative to input dir) in the sequence they should be walked, empty means no manifest")
+	mode := flag.String("mode", "literal", "Output mode: \"literal\" writes file contents into the Go source, \"embed\" writes them to a directory next to the output file and uses //go:embed (requires Go 1.16)")
+	embedDirName := flag.String("embed-dir", "", "Directory name for -mode=embed, relative to the output file, empty means the output file name without \".go\"")
+	gzipFiles := flag.Bool("gzip", true, "Set to 0 to store files uncompressed")
+	modTimeFrom := flag.String("modtime", "", "Modification time to record for files: empty means each file's mtime, \"git\" the time of the last commit of each file (mtime for uncommitted files), \"SOURCE_DATE_EPOCH\" that environment variable, or a fixed Unix timestamp or RFC 3339 time")
+	check := flag.Bool("check", false, "Do not write anything, instead exit non-zero with a summary if the existing output is not what would be generated")
+	meta := make(metaFlags)
+	flag.Var(meta, "meta", "Metadata for the module as key=value (e.g. license=MIT), may be repeated")
+	fromPackageJSON := flag.Bool("from-package-json", false, "Read package.json in the input directory: package only the files it publishes (files, main, browser, style), require its mapped dependencies and use its name, version, license etc. as metadata")
+	npmMapFile := flag.String("npm-map", "", "JSON file mapping npm package names to Go import paths for -from-package-json, empty means "+defaultNPMMapFile+" in the current directory if present")
+	configFile := flag.String("config", "", "Config file (JSON or TOML) providing defaults for these flags, empty means webresource.json or webresource.toml in the current directory if present, \"none\" disables")
+
+	// "mkwebresource import <archive>" reads its input from an npm tarball or WebJar instead of a directory
+	importArchive := len(os.Args) > 1 && os.Args[1] == "import"
+	if importArchive {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	var cfg *config
+	if *configFile == "" {
+		var err error
+		*configFile, err = findConfig(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *configFile != "" && *configFile != "none" {
+		var err error
+		cfg, err = readConfig(*configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Bad config file: %v\n", err)
+			os.Exit(1)
+		}
+		err = cfg.apply(flag.CommandLine)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Bad config file %s: %v\n", *configFile, err)
+			os.Exit(1)
+		}
+		for k, v := range cfg.Metadata {
+			if _, ok := meta[k]; !ok {
+				meta[k] = v
+			}
+		}
+	}
 
 	args := flag.Args()
 
-	if len(args) != 1 {
+	var inputDir string // empty when reading from an archive
+	var src http.FileSystem
+	switch {
+	case importArchive && len(args) == 1:
+		fs, archiveMeta, err := readArchive(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading archive %q: %v\n", args[0], err)
+			os.Exit(1)
+		}
+		src = fs
+		for k, v := range archiveMeta {
+			if _, ok := meta[k]; !ok {
+				meta[k] = v
+			}
+		}
+		// archives are packaged with their directory tree, and described by their package.json if they have one
+		if !flagGiven("R") {
+			*recursive = true
+		}
+		if !flagGiven("from-package-json") && inputFileExists(src, "/package.json") {
+			*fromPackageJSON = true
+		}
+	case importArchive:
+		fmt.Fprintf(os.Stderr, "You must provide exactly one archive (.tgz, .zip or .jar), e.g.: mkwebresource import bootstrap-5.3.0.tgz\n")
+		os.Exit(1)
+	case len(args) == 1:
+		inputDir = args[0]
+	case len(args) == 0 && cfg != nil:
+		inputDir = cfg.Dir
+		if inputDir == "" {
+			inputDir = filepath.Dir(*configFile)
+		}
+	default:
 		fmt.Fprintf(os.Stderr, "You must provide exactly one argument of the input directory, e.g.: mkwebresource .\n")
 		os.Exit(1)
 	}
-	inputDir := args[0]
+	if src == nil {
+		fi, err := os.Stat(inputDir)
+		if err != nil || !fi.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error opening input directory %q: not a directory\n", inputDir)
+			os.Exit(1)
+		}
+		src = http.Dir(inputDir)
+	}
 
 	*importName = strings.TrimSpace(*importName)
 	if *importName == "" {
@@ -41,61 +143,161 @@ func main() {
 		os.Exit(1)
 	}
 
-	// figure out package name, stripping off major semver if present
-	importNameShort := importLocalName(trimMajorSemver(*importName))
-
-	filter, err := regexp.Compile(*filterExpr)
+	// figure out package name, stripping off major semver if present, unless given as "path;name"
+	var importNameShort string
+	var err error
+	*importName, importNameShort, err = packageName(*importName)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Bad filter regexp %q: %v\n", *filterExpr, err)
+		fmt.Fprintf(os.Stderr, "Bad -p value: %v\n", err)
 		os.Exit(1)
 	}
 
-	var inputFilePaths []string
-
-	if !*recursive {
-		inputDirFile, err := os.Open(inputDir)
+	var pkgIncludes ruleFlags
+	if *fromPackageJSON {
+		pj, err := readPackageJSON(src, "/package.json")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error opening input directory %q: %v\n", inputDir, err)
+			fmt.Fprintf(os.Stderr, "Error reading package.json: %v\n", err)
 			os.Exit(1)
 		}
-		defer inputDirFile.Close()
-		fis, err := inputDirFile.Readdir(-1)
+
+		for k, v := range pj.metadata() {
+			if _, ok := meta[k]; !ok {
+				meta[k] = v
+			}
+		}
+
+		var pkgExcludes ruleFlags
+		pkgIncludes, pkgExcludes = pj.fileRules()
+		excludes = append(excludes, pkgExcludes...)
+		if !flagGiven("R") { // published files are listed relative to the package root
+			*recursive = true
+		}
+
+		npmMap, err := readNPMMap(firstNonEmpty(*npmMapFile, defaultNPMMapFile), *npmMapFile != "")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error calling readdir on input directory %q: %v\n", inputDir, err)
+			fmt.Fprintf(os.Stderr, "Error reading npm map: %v\n", err)
 			os.Exit(1)
 		}
-		for _, fi := range fis {
-			// skip dirs
-			if fi.IsDir() {
-				continue
-			}
-			// skip files that don't match filter
-			if !filter.MatchString(fi.Name()) {
-				continue
-			}
-			inputFilePaths = append(inputFilePaths, path.Clean("/"+fi.Name()))
+		pkgRequires, unmapped := pj.requires(npmMap)
+		for _, name := range unmapped {
+			fmt.Fprintf(os.Stderr, "Warning: npm dependency %q has no Go import path in %s, it will not be required (map it to \"\" to silence this)\n", name, firstNonEmpty(*npmMapFile, defaultNPMMapFile))
 		}
-	} else {
-		err := filepath.Walk(inputDir, func(p string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				return nil
-			}
-			if strings.HasPrefix(p, inputDir) {
-				p = strings.TrimPrefix(p, inputDir)
+		*requires = mergeRequires(*requires, pkgRequires)
+	}
+
+	var emb *embedOutput
+	switch *mode {
+	case "literal":
+	case "embed":
+		if *embedDirName == "" {
+			*embedDirName = strings.TrimSuffix(filepath.Base(*outputFile), ".go")
+		}
+		// //go:embed only accepts clean paths below the output file's directory
+		name := path.Clean(filepath.ToSlash(*embedDirName))
+		if filepath.IsAbs(*embedDirName) || path.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+			fmt.Fprintf(os.Stderr, "Bad -embed-dir %q: must be a subdirectory of the output file's directory\n", *embedDirName)
+			os.Exit(1)
+		}
+		emb = &embedOutput{
+			dir:   filepath.Join(filepath.Dir(*outputFile), filepath.FromSlash(name)),
+			name:  name,
+			files: make(map[string][]byte),
+		}
+		if inputRelPath(emb.dir, *outputFile) != "" {
+			fmt.Fprintf(os.Stderr, "Embed directory %q must not contain the output file\n", emb.dir)
+			os.Exit(1)
+		}
+		// The embed directory may be inside the input directory, as with the default name and
+		// "-R .", it is then excluded from the inputs.  It must not be the input directory or
+		// contain it, since writing it replaces the files the previous run wrote there.
+		if inputDir != "" {
+			absEmb, absIn := absPath(emb.dir), absPath(inputDir)
+			if (absEmb == absIn) || strings.HasPrefix(absIn+string(filepath.Separator), absEmb+string(filepath.Separator)) {
+				fmt.Fprintf(os.Stderr, "Embed directory %q must not be or contain the input directory\n", emb.dir)
+				os.Exit(1)
 			}
-			if !filter.MatchString(p) {
-				return nil
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Bad -mode %q, must be \"literal\" or \"embed\"\n", *mode)
+		os.Exit(1)
+	}
+
+	filter, err := newFileFilter(src, *filterExpr, includes, excludes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	// what we generate is never input, otherwise each run would package the previous run's output
+	if inputDir != "" {
+		outputs := []string{*outputFile}
+		if emb != nil {
+			outputs = append(outputs, emb.dir)
+		}
+		for _, o := range outputs {
+			if p := inputRelPath(inputDir, o); p != "" {
+				filter.outputs = append(filter.outputs, p)
 			}
-			inputFilePaths = append(inputFilePaths, path.Clean("/"+p))
-			return nil
-		})
+		}
+	}
+	filter.only, err = pkgIncludes.compile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad file list in package.json: %v\n", err)
+		os.Exit(1)
+	}
+	unmatched, err := unmatchedRules(src, pkgIncludes, filter.only)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input directory %q: %v\n", firstNonEmpty(inputDir, args[0]), err)
+		os.Exit(1)
+	}
+	for _, rule := range unmatched {
+		fmt.Fprintf(os.Stderr, "Warning: package.json files entry %q matches no file\n", rule)
+	}
+
+	inputFilePaths, err := listInputFiles(src, *recursive, filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input directory %q: %v\n", firstNonEmpty(inputDir, args[0]), err)
+		os.Exit(1)
+	}
+
+	var manifest []string
+	if *orderFile != "" {
+		manifest, err = readOrderFile(*orderFile, inputFilePaths)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading order file %q: %v\n", *orderFile, err)
+			os.Exit(1)
+		}
+		inputFilePaths = applyOrder(inputFilePaths, manifest)
+	} else if cfg != nil && len(cfg.Order) > 0 {
+		manifest, err = parseOrder(cfg.Order, "entry", inputFilePaths)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error calling readdir on input directory %q: %v\n", inputDir, err)
+			fmt.Fprintf(os.Stderr, "Bad \"order\" in config file %s: %v\n", *configFile, err)
 			os.Exit(1)
 		}
+		inputFilePaths = applyOrder(inputFilePaths, manifest)
+	}
+
+	if *list {
+		for _, p := range inputFilePaths {
+			fmt.Println(p)
+		}
+		return
+	}
+
+	modTime, err := modTimeFunc(*modTimeFrom, inputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad -modtime value: %v\n", err)
+		os.Exit(1)
+	}
+
+	var integrityAlgs []string
+	if *sriAlgs != "" {
+		integrityAlgs = strings.Split(*sriAlgs, ",")
+		for _, alg := range integrityAlgs {
+			if _, err := webresource.Integrity(alg, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Bad -sri value: %v\n", err)
+				os.Exit(1)
+			}
+		}
 	}
 
 	// log.Printf("inputFilePaths: %+v", inputFilePaths)
@@ -104,21 +306,34 @@ func main() {
 	fmt.Fprintf(&srcbuf, `package %s`+"\n", importNameShort)
 	fmt.Fprintf(&srcbuf, "\n")
 
-	fmt.Fprintf(&srcbuf, `import "time"`+"\n")
+	if emb != nil {
+		fmt.Fprintf(&srcbuf, `import "embed"`+"\n")
+	}
+	if len(inputFilePaths) > 0 { // an empty module, e.g. just created by init, has no modtimes
+		fmt.Fprintf(&srcbuf, `import "time"`+"\n")
+	}
 	fmt.Fprintf(&srcbuf, "\n")
 
 	fmt.Fprintf(&srcbuf, `import "github.com/gocaveman/webresource"`+"\n")
 	fmt.Fprintf(&srcbuf, "\n")
 
-	var requireList []string
+	var requireList []requireImport
 	if *requires != "" {
-		requireList = strings.Split(*requires, ",")
+		requireList, err = requireImports(strings.Split(*requires, ","))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Bad -r value: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// require imports
+	// require imports, aliased where the default name collides or is not an identifier
 	if len(requireList) > 0 {
 		for _, r := range requireList {
-			fmt.Fprintf(&srcbuf, `import %q`+"\n", r)
+			if r.alias {
+				fmt.Fprintf(&srcbuf, `import %s %q`+"\n", r.name, r.path)
+				continue
+			}
+			fmt.Fprintf(&srcbuf, `import %q`+"\n", r.path)
 		}
 		fmt.Fprintf(&srcbuf, "\n")
 	}
@@ -138,7 +353,7 @@ func main() {
 		fmt.Fprintf(&srcbuf, `func requires() []webresource.Module {`+"\n")
 		fmt.Fprintf(&srcbuf, `return []webresource.Module{`+"\n")
 		for _, r := range requireList {
-			fmt.Fprintf(&srcbuf, `%s.%s(),`+"\n", importLocalName(trimMajorSemver(r)), moduleFuncName)
+			fmt.Fprintf(&srcbuf, `%s.%s(),`+"\n", r.name, moduleFuncName)
 		}
 		fmt.Fprintf(&srcbuf, `}`+"\n")
 		fmt.Fprintf(&srcbuf, `}`+"\n")
@@ -147,14 +362,41 @@ func main() {
 	}
 	fmt.Fprintf(&srcbuf, "\n")
 
-	fmt.Fprintf(&srcbuf, `func addFiles(fs *webresource.FileSet) {`+"\n")
+	var filesbuf bytes.Buffer
 	for _, file := range inputFilePaths {
-		err := addFile(&srcbuf, inputDir, file)
+		err := addFile(&filesbuf, src, file, addFileOptions{integrityAlgs: integrityAlgs, gzip: *gzipFiles, modTime: modTime}, emb)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error adding file %q: %v\n", file, err)
 			os.Exit(1)
 		}
 	}
+
+	if emb != nil {
+		// each file is listed so names starting with "." or "_" are not skipped
+		for _, name := range emb.names {
+			fmt.Fprintf(&srcbuf, `//go:embed %q`+"\n", emb.name+"/"+name)
+		}
+		fmt.Fprintf(&srcbuf, `var embedded embed.FS`+"\n")
+		fmt.Fprintf(&srcbuf, "\n")
+	}
+
+	fmt.Fprintf(&srcbuf, `func addFiles(fs *webresource.FileSet) {`+"\n")
+	srcbuf.Write(filesbuf.Bytes())
+	if len(manifest) > 0 {
+		fmt.Fprintf(&srcbuf, `fs = fs.SetManifest(`+"\n")
+		for _, p := range manifest {
+			fmt.Fprintf(&srcbuf, `%q,`+"\n", p)
+		}
+		fmt.Fprintf(&srcbuf, `)`+"\n")
+	}
+	metaKeys := make([]string, 0, len(meta))
+	for k := range meta {
+		metaKeys = append(metaKeys, k)
+	}
+	sort.Strings(metaKeys)
+	for _, k := range metaKeys {
+		fmt.Fprintf(&srcbuf, `fs = fs.SetMetadata(%q, %q)`+"\n", k, meta[k])
+	}
 	fmt.Fprintf(&srcbuf, `}`+"\n")
 	fmt.Fprintf(&srcbuf, "\n")
 
@@ -166,6 +408,31 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *check {
+		err := checkOutput(*outputFile, srcbfmt, emb)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if emb != nil {
+		var prev []string
+		if prevSrc, err := ioutil.ReadFile(*outputFile); err == nil {
+			prev, err = generatedEmbedNames(prevSrc)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error parsing output file %q: %v\n", *outputFile, err)
+				os.Exit(1)
+			}
+		}
+		err = emb.write(prev)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing embed directory %q: %v\n", emb.dir, err)
+			os.Exit(1)
+		}
+	}
+
 	err = ioutil.WriteFile(*outputFile, srcbfmt, 0644)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing output file %q: %v\n", *outputFile, err)
@@ -174,9 +441,16 @@ func main() {
 
 }
 
-func addFile(w io.Writer, dir string, name string) error {
+// addFileOptions controls how addFile packages each file
+type addFileOptions struct {
+	integrityAlgs []string
+	gzip          bool
+	modTime       func(name string, fi os.FileInfo) time.Time
+}
 
-	f, err := os.Open(filepath.Join(dir, name))
+func addFile(w io.Writer, src http.FileSystem, name string, opts addFileOptions, emb *embedOutput) error {
+
+	f, err := src.Open(name)
 	if err != nil {
 		return err
 	}
@@ -192,27 +466,431 @@ func addFile(w io.Writer, dir string, name string) error {
 		return err
 	}
 
-	var buf bytes.Buffer
-	gw := gzip.NewWriter(&buf)
-	_, err = gw.Write(b)
+	modTime := opts.modTime(name, fi).Unix()
+	gzipFile := opts.gzip
+
+	data := b
+	if gzipFile {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		// no name or timestamp in the gzip header, so output only depends on content
+		gw.Header = gzip.Header{OS: 255}
+		_, err = gw.Write(b)
+		if err != nil {
+			return err
+		}
+		err = gw.Close()
+		if err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+
+	nameDir, _ := path.Split(path.Clean("/" + name))
+	if nameDir != "" && nameDir != "/" { // mkdirall if not root dir
+		fmt.Fprintf(w, `fs = fs.MkdirAll(%q, 0755)`+"\n", nameDir)
+	}
+	switch {
+	case emb != nil:
+		embName := strings.TrimPrefix(path.Clean("/"+name), "/")
+		if gzipFile {
+			embName += ".gz"
+		}
+		emb.add(embName, data)
+		fmt.Fprintf(w, `fs = fs.WriteFSFile(%q, 0644, time.Unix(%d, 0), embedded, %q)`+"\n", name, modTime, emb.name+"/"+embName)
+	case gzipFile:
+		fmt.Fprintf(w, `// compressed size: %d`+"\n", len(data))
+		fmt.Fprintf(w, `fs = fs.WriteGzipFile(%q, 0644, time.Unix(%d, 0), []byte(%q))`+"\n", name, modTime, data)
+	default:
+		fmt.Fprintf(w, `fs = fs.WriteFile(%q, 0644, time.Unix(%d, 0), []byte(%q))`+"\n", name, modTime, data)
+	}
+
+	if len(opts.integrityAlgs) > 0 {
+		fmt.Fprintf(w, `fs = fs.SetIntegrity(%q`, name)
+		for _, alg := range opts.integrityAlgs {
+			v, err := webresource.Integrity(alg, b)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, `, %q`, v)
+		}
+		fmt.Fprintf(w, `)`+"\n")
+	}
+
+	return nil
+}
+
+// listInputFiles returns the paths of the files in src which filter includes, sorted,
+// descending into directories if recursive is true.
+func listInputFiles(src http.FileSystem, recursive bool, filter *fileFilter) ([]string, error) {
+
+	var ret []string
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		f, err := src.Open(dir)
+		if err != nil {
+			return err
+		}
+		fis, err := f.Readdir(-1)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		// Readdir order is not stable
+		sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+		for _, fi := range fis {
+			p := path.Join(dir, fi.Name())
+			if fi.IsDir() {
+				if !recursive || filter.skipDir(p) {
+					continue
+				}
+				if err := walk(p); err != nil {
+					return err
+				}
+				continue
+			}
+			// skip files that don't match filter
+			if !filter.includeFile(p) {
+				continue
+			}
+			ret = append(ret, p)
+		}
+		return nil
+	}
+
+	return ret, walk("/")
+}
+
+// readInputFile returns the contents of the file at fullPath in src
+func readInputFile(src http.FileSystem, fullPath string) ([]byte, error) {
+	f, err := src.Open(fullPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = gw.Close()
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func inputFileExists(src http.FileSystem, fullPath string) bool {
+	f, err := src.Open(fullPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	return err == nil && !fi.IsDir()
+}
+
+// flagGiven returns true if the named flag was set on the command line or from the config file.
+func flagGiven(name string) bool {
+	ret := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			ret = true
+		}
+	})
+	return ret
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// mergeRequires appends the import paths in more to the comma separated list in requires, skipping duplicates.
+func mergeRequires(requires string, more []string) string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, r := range append(strings.Split(requires, ","), more...) {
+		r = strings.TrimSpace(r)
+		if r == "" || seen[r] {
+			continue
+		}
+		seen[r] = true
+		ret = append(ret, r)
+	}
+	return strings.Join(ret, ",")
+}
+
+// modTimeFunc returns a function giving the modification time to record for each file
+// (named relative to inputDir, with a leading slash) according to the -modtime value.
+func modTimeFunc(from string, inputDir string) (func(name string, fi os.FileInfo) time.Time, error) {
+
+	fixed := func(t time.Time) func(string, os.FileInfo) time.Time {
+		return func(string, os.FileInfo) time.Time { return t }
+	}
+
+	switch from {
+	case "":
+		return func(name string, fi os.FileInfo) time.Time { return fi.ModTime() }, nil
+	case "git":
+		if inputDir == "" {
+			return nil, fmt.Errorf("\"git\" needs an input directory")
+		}
+		times, err := gitModTimes(inputDir)
+		if err != nil {
+			return nil, err
+		}
+		return func(name string, fi os.FileInfo) time.Time {
+			if t, ok := times[name]; ok {
+				return t
+			}
+			return fi.ModTime()
+		}, nil
+	case "SOURCE_DATE_EPOCH":
+		v := os.Getenv("SOURCE_DATE_EPOCH")
+		if v == "" {
+			return nil, fmt.Errorf("SOURCE_DATE_EPOCH is not set")
+		}
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %v", v, err)
+		}
+		return fixed(time.Unix(sec, 0)), nil
+	}
+
+	if sec, err := strconv.ParseInt(from, 10, 64); err == nil {
+		return fixed(time.Unix(sec, 0)), nil
+	}
+	t, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not \"git\", \"SOURCE_DATE_EPOCH\", a Unix timestamp or an RFC 3339 time", from)
+	}
+	return fixed(t), nil
+}
+
+// gitModTimes returns the time of the most recent commit touching each file under dir,
+// keyed by path relative to dir with a leading slash.
+func gitModTimes(dir string) (map[string]time.Time, error) {
+
+	cmd := exec.Command("git", "log", "--format=#%ct", "--name-only", "--relative", "--", ".")
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running git log in %q: %v", dir, err)
+	}
+
+	ret := make(map[string]time.Time)
+	var t time.Time
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			sec, err := strconv.ParseInt(line[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git log output %q", line)
+			}
+			t = time.Unix(sec, 0)
+			continue
+		}
+		name := path.Clean("/" + line)
+		if _, ok := ret[name]; !ok { // log is newest first
+			ret[name] = t
+		}
+	}
+
+	return ret, nil
+}
+
+// checkOutput compares the existing output file (and embed directory) with the regenerated
+// source and embed files, returning an error with a summary of what changed if they differ.
+func checkOutput(outputFile string, src []byte, emb *embedOutput) error {
+
+	regenerated, err := parseGenerated(src, func(name string) ([]byte, error) {
+		if emb == nil {
+			return nil, fmt.Errorf("unexpected embedded file %q", name)
+		}
+		b, ok := emb.files[strings.TrimPrefix(name, emb.name+"/")]
+		if !ok {
+			return nil, fmt.Errorf("unknown embedded file %q", name)
+		}
+		return b, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error parsing generated source: %v", err)
+	}
+
+	existingSrc, err := ioutil.ReadFile(outputFile)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s does not exist, run go generate", outputFile)
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading output file %q: %v", outputFile, err)
+	}
+	existing, err := parseGenerated(existingSrc, func(name string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(filepath.Dir(outputFile), filepath.FromSlash(name)))
+	})
+	if err != nil {
+		return fmt.Errorf("Error parsing output file %q: %v", outputFile, err)
+	}
+
+	var buf bytes.Buffer
+	if diffGenerated(&buf, existing, regenerated) {
+		return fmt.Errorf("%s is out of date, run go generate:\n%s", outputFile, strings.TrimSuffix(buf.String(), "\n"))
+	}
+
+	return nil
+}
+
+// embedOutput collects the files written to the embed directory for -mode=embed
+type embedOutput struct {
+	dir   string            // directory on disk
+	name  string            // directory name as used in //go:embed, relative to the output file
+	names []string          // file names relative to dir, slash separated, in the sequence added
+	files map[string][]byte // file contents keyed by name
+}
+
+func (e *embedOutput) add(name string, b []byte) {
+	e.names = append(e.names, name)
+	e.files[name] = b
+}
+
+// write writes the files to the embed directory.  Of the files already there only those
+// listed in prev, the //go:embed names in the previous output, are replaced or removed;
+// anything else in the directory was not written by mkwebresource and is an error.
+func (e *embedOutput) write(prev []string) error {
+
+	prevFiles := make(map[string]bool, len(prev))
+	for _, name := range prev {
+		if strings.HasPrefix(name, e.name+"/") {
+			prevFiles[strings.TrimPrefix(name, e.name+"/")] = true
+		}
+	}
+
+	var foreign []string
+	err := filepath.Walk(e.dir, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(e.dir, p)
+		if err != nil {
+			return err
+		}
+		if !prevFiles[filepath.ToSlash(rel)] {
+			foreign = append(foreign, filepath.ToSlash(rel))
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	if len(foreign) > 0 {
+		return fmt.Errorf("it contains files the previous output does not list, not overwriting: %s", strings.Join(foreign, ", "))
+	}
 
-	nameDir, _ := path.Split(path.Clean("/" + name))
-	if nameDir != "" && nameDir != "/" { // mkdirall if not root dir
-		fmt.Fprintf(w, `fs = fs.MkdirAll(%q, 0755)`+"\n", nameDir)
+	for name := range prevFiles {
+		if _, ok := e.files[name]; ok {
+			continue
+		}
+		err := os.Remove(filepath.Join(e.dir, filepath.FromSlash(name)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for _, name := range e.names {
+		p := filepath.Join(e.dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(p, e.files[name], 0644)
+		if err != nil {
+			return err
+		}
 	}
-	fmt.Fprintf(w, `// compressed size: %d`+"\n", buf.Len())
-	fmt.Fprintf(w, `fs = fs.WriteGzipFile(%q, 0644, time.Unix(%d, 0), []byte(%q))`+"\n", name, fi.ModTime().Unix(), buf.String())
 
 	return nil
 }
 
+// inputRelPath returns p relative to inputDir with a leading slash, as the input files
+// are named, or "" if p is not inside inputDir.
+func inputRelPath(inputDir, p string) string {
+	rel, err := filepath.Rel(absPath(inputDir), absPath(p))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+func absPath(p string) string {
+	ret, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+	return ret
+}
+
+// readOrderFile reads the list of paths from an order file, one per line, blank lines
+// and lines starting with # are ignored.  Each path must be one of inputFilePaths.
+func readOrderFile(fname string, inputFilePaths []string) ([]string, error) {
+
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return parseOrder(strings.Split(string(b), "\n"), "line", inputFilePaths)
+}
+
+// parseOrder parses order entries as read by readOrderFile, label names an entry in errors.
+func parseOrder(lines []string, label string, inputFilePaths []string) ([]string, error) {
+
+	known := make(map[string]bool, len(inputFilePaths))
+	for _, p := range inputFilePaths {
+		known[p] = true
+	}
+
+	var ret []string
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := path.Clean("/" + filepath.ToSlash(line))
+		if !known[p] {
+			return nil, fmt.Errorf("%s %d: %q is not one of the input files", label, i+1, line)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("%s %d: %q is listed more than once", label, i+1, line)
+		}
+		seen[p] = true
+		ret = append(ret, p)
+	}
+
+	return ret, nil
+}
+
+// applyOrder returns inputFilePaths with the entries from order moved to the front in that sequence.
+func applyOrder(inputFilePaths []string, order []string) []string {
+	ordered := make(map[string]bool, len(order))
+	ret := make([]string, 0, len(inputFilePaths))
+	for _, p := range order {
+		ordered[p] = true
+		ret = append(ret, p)
+	}
+	for _, p := range inputFilePaths {
+		if !ordered[p] {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
 func trimMajorSemver(p string) string {
 	subm := regexp.MustCompile(`(.*)/v[0-9]+$`).FindStringSubmatch(p)
 	if len(subm) > 1 {
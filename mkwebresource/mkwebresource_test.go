@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the tool itself when re-executed by runTool
+func TestMain(m *testing.M) {
+	if os.Getenv("MKWEBRESOURCE_TEST_MAIN") == "1" {
+		os.Args = append([]string{"mkwebresource"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTool runs mkwebresource with args in dir and returns its combined output
+func runTool(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "MKWEBRESOURCE_TEST_MAIN=1")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// writeTestFiles creates files (slash separated names) with their contents under dir
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRerunOutput(t *testing.T) {
+
+	for _, tc := range []struct {
+		args   []string
+		writes int
+	}{
+		// "*" would match the output file and the embed directory
+		{[]string{"-p", "example.com/lib", "-mode=embed", "-gzip=0", "-modtime=0", "-R", "-include", "*", "."}, 4},
+		{[]string{"-p", "example.com/lib", "-mode=literal", "-modtime=0", "-R", "-include", "*", "."}, 4},
+		{[]string{"-p", "example.com/lib", "-mode=embed", "-modtime=0", "-R", "."}, 3},
+	} {
+		args := tc.args
+
+		dir, err := ioutil.TempDir("", "mkwebresource")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		writeTestFiles(t, dir, map[string]string{
+			"a.js":      "a()",
+			"b.css":     "b{}",
+			"sub/c.js":  "c()",
+			"README.md": "only packaged with -include *",
+		})
+
+		var first []byte
+		for i := 0; i < 3; i++ {
+			out, err := runTool(t, dir, args...)
+			if err != nil {
+				t.Fatalf("%v: run %d: %v\n%s", args, i+1, err, out)
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dir, "webresource-data.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i == 0 {
+				first = b
+				continue
+			}
+			if !bytes.Equal(first, b) {
+				t.Fatalf("%v: run %d changed the output:\n%s", args, i+1, b)
+			}
+		}
+		if n := strings.Count(string(first), "fs.Write"); n != tc.writes {
+			t.Errorf("%v: expected %d files, got %d:\n%s", args, tc.writes, n, first)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "webresource-data", "webresource-data")); err == nil {
+			t.Errorf("%v: embed directory was packaged into itself", args)
+		}
+	}
+}
+
+func TestEmbedDirContainingInput(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFiles(t, dir, map[string]string{"web/a.js": "a()"})
+
+	out, err := runTool(t, dir, "-p", "example.com/lib", "-mode=embed", "-embed-dir", "web", "web")
+	if err == nil || !strings.Contains(out, "must not be or contain the input directory") {
+		t.Fatalf("expected error, got %v: %s", err, out)
+	}
+}
+
+func TestEmbedDirSafety(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFiles(t, dir, map[string]string{
+		"pkg/doc.go":         "package pkg\n",
+		"pkg/notes.txt":      "mine",
+		"pkg/data/notes.txt": "also mine",
+		"assets/a.js":        "a()",
+		"assets/b.js":        "b()",
+	})
+	pkg := filepath.Join(dir, "pkg")
+
+	for _, tc := range []struct {
+		embedDir, wantErr string
+	}{
+		{".", "must be a subdirectory"},
+		{"./", "must be a subdirectory"},
+		{"..", "must be a subdirectory"},
+		{"../assets", "must be a subdirectory"},
+		{"x/../..", "must be a subdirectory"},
+		{filepath.Join(dir, "out"), "must be a subdirectory"},
+		{"data", "contains files the previous output does not list, not overwriting: notes.txt"},
+	} {
+		out, err := runTool(t, pkg, "-p", "example.com/pkg", "-mode=embed", "-embed-dir="+tc.embedDir, "../assets")
+		if err == nil || !strings.Contains(out, tc.wantErr) {
+			t.Errorf("-embed-dir=%s: expected error containing %q, got %v: %s", tc.embedDir, tc.wantErr, err, out)
+		}
+	}
+	for _, name := range []string{"doc.go", "notes.txt", "data/notes.txt"} {
+		if _, err := os.Stat(filepath.Join(pkg, filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s was removed: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(pkg, "webresource-data.go")); err == nil {
+		t.Errorf("output written despite errors")
+	}
+
+	// only files the previous output lists are removed
+	args := []string{"-p", "example.com/pkg", "-mode=embed", "-embed-dir=./gen/", "../assets"}
+	if out, err := runTool(t, pkg, args...); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	src, err := ioutil.ReadFile(filepath.Join(pkg, "webresource-data.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), `//go:embed "gen/a.js.gz"`) {
+		t.Fatalf("expected clean embed names:\n%s", src)
+	}
+	os.Remove(filepath.Join(dir, "assets", "b.js"))
+	if out, err := runTool(t, pkg, args...); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	fis, err := ioutil.ReadDir(filepath.Join(pkg, "gen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 || fis[0].Name() != "a.js.gz" {
+		t.Errorf("expected only a.js.gz in the embed directory, got %v", fis)
+	}
+}
+
+func TestPackageJSONFiles(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFiles(t, dir, map[string]string{
+		"package.json":                    `{"name": "bootstrap", "files": ["dist/{css,js}/*.{css,js,map}", "js/dist/*.js", "scss/**/*.scss"]}`,
+		"dist/css/bootstrap.css":          "a{}",
+		"dist/js/bootstrap.bundle.js":     "b()",
+		"dist/js/bootstrap.bundle.js.map": "{}",
+		"js/src/alert.js":                 "c()",
+	})
+
+	out, err := runTool(t, dir, "-p", "example.com/bootstrap", "-modtime=0", "-from-package-json", "-R", ".")
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	for _, rule := range []string{"/js/dist/*.js", "/scss/**/*.scss"} {
+		if !strings.Contains(out, fmt.Sprintf("Warning: package.json files entry %q matches no file", rule)) {
+			t.Errorf("no warning for %q: %s", rule, out)
+		}
+	}
+	if strings.Contains(out, "dist/{css,js}") {
+		t.Errorf("unexpected warning: %s", out)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "webresource-data.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/dist/css/bootstrap.css", "/dist/js/bootstrap.bundle.js"} {
+		if !strings.Contains(string(b), fmt.Sprintf("%q", p)) {
+			t.Errorf("%s not packaged", p)
+		}
+	}
+	if strings.Contains(string(b), "alert.js") {
+		t.Errorf("/js/src/alert.js is not published but was packaged")
+	}
+}
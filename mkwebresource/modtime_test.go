@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModTimeFunc(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestFiles(t, dir, map[string]string{"a.js": "a()"})
+	mtime := time.Unix(1400000000, 0)
+	if err := os.Chtimes(filepath.Join(dir, "a.js"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "a.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Setenv("SOURCE_DATE_EPOCH", os.Getenv("SOURCE_DATE_EPOCH"))
+	os.Setenv("SOURCE_DATE_EPOCH", "1600000000")
+
+	for _, tc := range []struct {
+		from string
+		want int64
+	}{
+		{"", 1400000000},
+		{"SOURCE_DATE_EPOCH", 1600000000},
+		{"0", 0},
+		{"1623758400", 1623758400},
+		{"2021-06-15T12:00:00Z", 1623758400},
+		{"2021-06-15T14:00:00+02:00", 1623758400},
+	} {
+		f, err := modTimeFunc(tc.from, dir)
+		if err != nil {
+			t.Errorf("%q: %v", tc.from, err)
+			continue
+		}
+		if got := f("/a.js", fi).Unix(); got != tc.want {
+			t.Errorf("%q: got %d, want %d", tc.from, got, tc.want)
+		}
+	}
+
+	for _, from := range []string{"yesterday", "2021-06-15", "2021-06-15 12:00:00"} {
+		if _, err := modTimeFunc(from, dir); err == nil || !strings.Contains(err.Error(), `is not "git"`) {
+			t.Errorf("%q: expected error, got %v", from, err)
+		}
+	}
+	if _, err := modTimeFunc("git", ""); err == nil {
+		t.Errorf("expected error for git without an input directory")
+	}
+	os.Setenv("SOURCE_DATE_EPOCH", "soon")
+	if _, err := modTimeFunc("SOURCE_DATE_EPOCH", dir); err == nil || !strings.Contains(err.Error(), "invalid SOURCE_DATE_EPOCH") {
+		t.Errorf("expected error for bad SOURCE_DATE_EPOCH, got %v", err)
+	}
+	os.Unsetenv("SOURCE_DATE_EPOCH")
+	if _, err := modTimeFunc("SOURCE_DATE_EPOCH", dir); err == nil || !strings.Contains(err.Error(), "not set") {
+		t.Errorf("expected error for unset SOURCE_DATE_EPOCH, got %v", err)
+	}
+}
+
+func TestModTimeGit(t *testing.T) {
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	git := func(date string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date, "GIT_CONFIG_NOSYSTEM=1", "HOME="+dir)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	// the input is a subdirectory, paths are relative to it
+	writeTestFiles(t, dir, map[string]string{"web/a.js": "a()", "web/x/b.js": "b()"})
+	git("", "init", "-q", ".")
+	git("", "add", ".")
+	git("2020-01-01T00:00:00Z", "commit", "-q", "-m", "first")
+	writeTestFiles(t, dir, map[string]string{"web/x/b.js": "b2()", "web/c.js": "uncommitted()"})
+	git("", "add", "web/x/b.js")
+	git("2021-06-15T12:00:00Z", "commit", "-q", "-m", "second")
+
+	web := filepath.Join(dir, "web")
+	times, err := gitModTimes(web)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 2 || times["/a.js"].Unix() != 1577836800 || times["/x/b.js"].Unix() != 1623758400 {
+		t.Errorf("unexpected times: %v", times)
+	}
+
+	// files not committed keep their mtime
+	mtime := time.Unix(1400000000, 0)
+	if err := os.Chtimes(filepath.Join(web, "c.js"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runTool(t, dir, "-p", "example.com/lib", "-R", "-modtime=git", "web")
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "webresource-data.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, sec := range map[string]int{"/a.js": 1577836800, "/x/b.js": 1623758400, "/c.js": 1400000000} {
+		if !strings.Contains(string(b), fmt.Sprintf("(%q, 0644, time.Unix(%d, 0),", name, sec)) {
+			t.Errorf("%s not recorded with time %d:\n%s", name, sec, b)
+		}
+	}
+}
+
+func TestReproducibleOutput(t *testing.T) {
+
+	defer os.Setenv("SOURCE_DATE_EPOCH", os.Getenv("SOURCE_DATE_EPOCH"))
+	os.Setenv("SOURCE_DATE_EPOCH", "1600000000")
+
+	for _, mode := range []string{"literal", "embed"} {
+
+		dir, err := ioutil.TempDir("", "mkwebresource")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		writeTestFiles(t, dir, map[string]string{"web/a.js": strings.Repeat("a();", 100), "web/b.css": "b{}"})
+
+		// run returns everything the tool writes, keyed by path relative to dir
+		run := func(mtime time.Time) map[string]string {
+			for _, name := range []string{"a.js", "b.css"} {
+				if err := os.Chtimes(filepath.Join(dir, "web", name), mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out, err := runTool(t, dir, "-p", "example.com/lib", "-mode="+mode, "-modtime=SOURCE_DATE_EPOCH", "web")
+			if err != nil {
+				t.Fatalf("%s: %v: %s", mode, err, out)
+			}
+			ret := make(map[string]string)
+			err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+				if err != nil || fi.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(dir, p)
+				if err != nil || strings.HasPrefix(rel, "web"+string(filepath.Separator)) {
+					return err
+				}
+				b, err := ioutil.ReadFile(p)
+				ret[filepath.ToSlash(rel)] = string(b)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ret
+		}
+
+		first := run(time.Unix(1500000000, 0))
+		second := run(time.Now())
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("%s: output differs between runs with different file mtimes", mode)
+		}
+		if mode == "embed" && len(first) != 3 {
+			t.Errorf("%s: expected the generated file and two embedded files, got %d files", mode, len(first))
+		}
+		if !strings.Contains(first["webresource-data.go"], "time.Unix(1600000000, 0)") {
+			t.Errorf("%s: SOURCE_DATE_EPOCH not used:\n%s", mode, first["webresource-data.go"])
+		}
+	}
+}
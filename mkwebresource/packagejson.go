@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultNPMMapFile maps npm package names to Go import paths for -from-package-json
+const defaultNPMMapFile = "webresource-npm.json"
+
+// packageJSON is the part of an npm package.json which mkwebresource uses
+type packageJSON struct {
+	Name             string            `json:"name"`
+	Version          string            `json:"version"`
+	Description      string            `json:"description"`
+	Homepage         string            `json:"homepage"`
+	License          json.RawMessage   `json:"license"` // "MIT" or {"type": "MIT", ...}
+	Main             string            `json:"main"`
+	Style            string            `json:"style"`
+	Browser          json.RawMessage   `json:"browser"` // "file.js" or {"./a.js": "./b.js", "fs": false}
+	Files            []string          `json:"files"`
+	Dependencies     map[string]string `json:"dependencies"`
+	PeerDependencies map[string]string `json:"peerDependencies"`
+}
+
+func readPackageJSON(src http.FileSystem, fname string) (*packageJSON, error) {
+	b, err := readInputFile(src, fname)
+	if err != nil {
+		return nil, err
+	}
+	var ret packageJSON
+	err = json.Unmarshal(b, &ret)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fname, err)
+	}
+	return &ret, nil
+}
+
+// metadata returns the descriptive fields which are set
+func (pj *packageJSON) metadata() map[string]string {
+
+	ret := make(map[string]string)
+	for k, v := range map[string]string{
+		"name":        pj.Name,
+		"version":     pj.Version,
+		"description": pj.Description,
+		"homepage":    pj.Homepage,
+	} {
+		if v != "" {
+			ret[k] = v
+		}
+	}
+
+	var license string
+	if json.Unmarshal(pj.License, &license) != nil {
+		var lo struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(pj.License, &lo)
+		license = lo.Type
+	}
+	if license != "" {
+		ret["license"] = license
+	}
+
+	return ret
+}
+
+// fileRules returns the rules for the files the package publishes, from files, main,
+// browser and style, relative to the package root.  Entries in files starting with "!"
+// are excludes.  Both are empty if the package does not say.
+func (pj *packageJSON) fileRules() (includes, excludes ruleFlags) {
+
+	anchor := func(p string) string {
+		return "/" + strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(p), "./"), "/")
+	}
+
+	for _, f := range pj.Files {
+		if strings.HasPrefix(f, "!") {
+			excludes = append(excludes, anchor(f[1:]))
+			continue
+		}
+		includes = append(includes, anchor(f))
+	}
+
+	var browser []string
+	var bs string
+	if json.Unmarshal(pj.Browser, &bs) == nil {
+		browser = append(browser, bs)
+	} else {
+		// object form, replacement file names are the values, false means excluded
+		var bm map[string]interface{}
+		json.Unmarshal(pj.Browser, &bm)
+		for _, v := range bm {
+			if s, ok := v.(string); ok {
+				browser = append(browser, s)
+			}
+		}
+		sort.Strings(browser)
+	}
+
+	for _, f := range append([]string{pj.Main, pj.Style}, browser...) {
+		if f != "" {
+			includes = append(includes, anchor(f))
+		}
+	}
+
+	return includes, excludes
+}
+
+// requires returns the Go import paths of the package's dependencies and peer dependencies
+// according to npmMap, sorted by npm name.  Dependencies mapped to "" are skipped
+// deliberately, those not in npmMap at all are returned as unmapped.
+func (pj *packageJSON) requires(npmMap map[string]string) (paths []string, unmapped []string) {
+
+	names := make(map[string]bool)
+	for name := range pj.Dependencies {
+		names[name] = true
+	}
+	for name := range pj.PeerDependencies {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		p, ok := npmMap[name]
+		switch {
+		case !ok:
+			unmapped = append(unmapped, name)
+		case p != "":
+			paths = append(paths, p)
+		}
+	}
+
+	return paths, unmapped
+}
+
+// readNPMMap reads a JSON object of npm package names to Go import paths, e.g.
+// {"jquery": "github.com/gocaveman-libs/jquery", "fsevents": ""}.
+// A missing file is only an error if required is true.
+func readNPMMap(fname string, required bool) (map[string]string, error) {
+	b, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) && !required {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string)
+	err = json.Unmarshal(b, &ret)
+	if err != nil {
+		return nil, fmt.Errorf("%s: must be a JSON object of npm package names to Go import paths: %v", fname, err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocaveman/webresource"
+)
+
+// parsePackageJSON reads content the way -from-package-json does
+func parsePackageJSON(t *testing.T, content string) *packageJSON {
+	t.Helper()
+	src := webresource.NewFileSet("test").WriteFile("/package.json", 0644, time.Time{}, []byte(content))
+	pj, err := readPackageJSON(src, "/package.json")
+	if err != nil {
+		t.Fatalf("%s: %v", content, err)
+	}
+	return pj
+}
+
+func TestPackageJSONMetadata(t *testing.T) {
+
+	for _, tc := range []struct {
+		in   string
+		want map[string]string
+	}{
+		{`{}`, map[string]string{}},
+		{`{"name": "lib", "version": "1.0.0", "description": "A lib", "homepage": "https://example.com/", "license": "MIT"}`, map[string]string{
+			"name": "lib", "version": "1.0.0", "description": "A lib", "homepage": "https://example.com/", "license": "MIT",
+		}},
+		// the older object form
+		{`{"name": "lib", "license": {"type": "BSD-3-Clause", "url": "https://example.com/LICENSE"}}`, map[string]string{"name": "lib", "license": "BSD-3-Clause"}},
+		// empty and unexpected values are left out
+		{`{"name": "", "license": ""}`, map[string]string{}},
+		{`{"license": ["MIT"]}`, map[string]string{}},
+	} {
+		if got := parsePackageJSON(t, tc.in).metadata(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.in, got, tc.want)
+		}
+	}
+
+	src := webresource.NewFileSet("test").WriteFile("/package.json", 0644, time.Time{}, []byte(`{"name": 1}`))
+	if _, err := readPackageJSON(src, "/package.json"); err == nil || !strings.Contains(err.Error(), "/package.json:") {
+		t.Errorf("expected error naming the file, got %v", err)
+	}
+}
+
+func TestPackageJSONFileRules(t *testing.T) {
+
+	for _, tc := range []struct {
+		in                 string
+		includes, excludes ruleFlags
+	}{
+		{`{}`, nil, nil},
+		{`{"files": ["dist", "./src/", "/types/*.d.ts", "!dist/*.map", "!./test/"]}`,
+			ruleFlags{"/dist", "/src/", "/types/*.d.ts"},
+			ruleFlags{"/dist/*.map", "/test/"}},
+		{`{"main": "./index.js", "style": "dist/lib.css"}`, ruleFlags{"/index.js", "/dist/lib.css"}, nil},
+		// browser as a string replaces main for browsers
+		{`{"main": "index.js", "browser": "./browser.js"}`, ruleFlags{"/index.js", "/browser.js"}, nil},
+		// browser as an object, only replacement files are published, false means the module is left out
+		{`{"files": ["lib/"], "browser": {"./lib/node.js": "./lib/web.js", "fs": false, "./lib/a.js": "./lib/b.js"}}`,
+			ruleFlags{"/lib/", "/lib/b.js", "/lib/web.js"}, nil},
+	} {
+		includes, excludes := parsePackageJSON(t, tc.in).fileRules()
+		if !reflect.DeepEqual(includes, tc.includes) || !reflect.DeepEqual(excludes, tc.excludes) {
+			t.Errorf("%s: got %q, %q, want %q, %q", tc.in, includes, excludes, tc.includes, tc.excludes)
+		}
+	}
+}
+
+func TestPackageJSONRequires(t *testing.T) {
+
+	npmMap := map[string]string{
+		"jquery":    "github.com/gocaveman-libs/jquery",
+		"popper.js": "github.com/gocaveman-libs/popper",
+		"fsevents":  "",
+	}
+
+	for _, tc := range []struct {
+		in             string
+		paths, unknown []string
+	}{
+		{`{}`, nil, nil},
+		// sorted by npm name, dependencies and peer dependencies alike
+		{`{"dependencies": {"popper.js": "^1.0", "jquery": "^3.0"}}`, []string{"github.com/gocaveman-libs/jquery", "github.com/gocaveman-libs/popper"}, nil},
+		{`{"peerDependencies": {"jquery": "^3.0"}, "dependencies": {"jquery": "^3.0"}}`, []string{"github.com/gocaveman-libs/jquery"}, nil},
+		// mapped to "" is skipped on purpose, not in the map is reported
+		{`{"dependencies": {"fsevents": "*", "left-pad": "*", "jquery": "*", "is-odd": "*"}}`, []string{"github.com/gocaveman-libs/jquery"}, []string{"is-odd", "left-pad"}},
+	} {
+		paths, unknown := parsePackageJSON(t, tc.in).requires(npmMap)
+		if !reflect.DeepEqual(paths, tc.paths) || !reflect.DeepEqual(unknown, tc.unknown) {
+			t.Errorf("%s: got %q, %q, want %q, %q", tc.in, paths, unknown, tc.paths, tc.unknown)
+		}
+	}
+}
+
+func TestReadNPMMap(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "mkwebresource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFiles(t, dir, map[string]string{
+		"good.json": `{"jquery": "github.com/gocaveman-libs/jquery", "fsevents": ""}`,
+		"bad.json":  `["jquery"]`,
+	})
+	missing := filepath.Join(dir, "missing.json")
+
+	for _, required := range []bool{false, true} {
+		m, err := readNPMMap(filepath.Join(dir, "good.json"), required)
+		if err != nil || !reflect.DeepEqual(m, map[string]string{"jquery": "github.com/gocaveman-libs/jquery", "fsevents": ""}) {
+			t.Errorf("required=%v: got %v, %v", required, m, err)
+		}
+		if _, err := readNPMMap(filepath.Join(dir, "bad.json"), required); err == nil || !strings.Contains(err.Error(), "must be a JSON object") {
+			t.Errorf("required=%v: expected error for bad map, got %v", required, err)
+		}
+	}
+
+	// a missing map is fine unless it was asked for
+	if m, err := readNPMMap(missing, false); err != nil || len(m) != 0 {
+		t.Errorf("optional missing map: got %v, %v", m, err)
+	}
+	if _, err := readNPMMap(missing, true); !os.IsNotExist(err) {
+		t.Errorf("required missing map: expected not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML a config file needs: key/value pairs with string,
+// boolean, integer and string array values, plus [table] sections of the same.
+func parseTOML(b []byte) (map[string]interface{}, error) {
+	p := &tomlParser{s: string(b), line: 1}
+	ret, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", p.line, err)
+	}
+	return ret, nil
+}
+
+type tomlParser struct {
+	s    string
+	i    int
+	line int
+}
+
+func (p *tomlParser) parse() (map[string]interface{}, error) {
+
+	root := make(map[string]interface{})
+	table := root
+
+	for {
+		p.skipSpace(true)
+		if p.i >= len(p.s) {
+			return root, nil
+		}
+
+		if p.s[p.i] == '[' {
+			p.i++
+			p.skipSpace(false)
+			name, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if !p.consume(']') {
+				return nil, fmt.Errorf("expected ] after table name %q", name)
+			}
+			if _, ok := root[name]; ok {
+				return nil, fmt.Errorf("%q is defined more than once", name)
+			}
+			table = make(map[string]interface{})
+			root[name] = table
+			if err := p.endOfLine(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		k, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if !p.consume('=') {
+			return nil, fmt.Errorf("expected = after key %q", k)
+		}
+		p.skipSpace(false)
+		v, err := p.value()
+		if err != nil {
+			return nil, fmt.Errorf("value of %q: %v", k, err)
+		}
+		if _, ok := table[k]; ok {
+			return nil, fmt.Errorf("%q is defined more than once", k)
+		}
+		table[k] = v
+		if err := p.endOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// skipSpace skips whitespace and comments, and newlines if newlines is true
+func (p *tomlParser) skipSpace(newlines bool) {
+	for p.i < len(p.s) {
+		switch c := p.s[p.i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.i++
+		case c == '\n' && newlines:
+			p.i++
+			p.line++
+		case c == '#':
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace(false)
+	if p.i < len(p.s) && p.s[p.i] != '\n' {
+		return fmt.Errorf("unexpected %q, expected end of line", p.s[p.i:p.i+1])
+	}
+	return nil
+}
+
+func (p *tomlParser) consume(c byte) bool {
+	if p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *tomlParser) key() (string, error) {
+	if p.i < len(p.s) && (p.s[p.i] == '"' || p.s[p.i] == '\'') {
+		return p.str()
+	}
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			break
+		}
+		p.i++
+	}
+	if p.i == start {
+		return "", fmt.Errorf("expected a key")
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+
+	if p.i >= len(p.s) {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	switch c := p.s[p.i]; {
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '[':
+		p.i++
+		ret := []interface{}{}
+		for {
+			p.skipSpace(true)
+			if p.consume(']') {
+				return ret, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+			p.skipSpace(true)
+			if p.consume(']') {
+				return ret, nil
+			}
+			if !p.consume(',') {
+				return nil, fmt.Errorf("expected , or ] in array")
+			}
+		}
+	case strings.HasPrefix(p.s[p.i:], "true"):
+		p.i += len("true")
+		return true, nil
+	case strings.HasPrefix(p.s[p.i:], "false"):
+		p.i += len("false")
+		return false, nil
+	case c == '-' || c == '+' || c >= '0' && c <= '9':
+		start := p.i
+		p.i++
+		for p.i < len(p.s) && (p.s[p.i] >= '0' && p.s[p.i] <= '9' || p.s[p.i] == '_') {
+			p.i++
+		}
+		n, err := strconv.ParseInt(strings.Replace(p.s[start:p.i], "_", "", -1), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported number %q", p.s[start:p.i])
+		}
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value starting with %q", p.s[p.i:p.i+1])
+}
+
+// str parses a basic ("...") or literal ('...') single line string
+func (p *tomlParser) str() (string, error) {
+	q := p.s[p.i]
+	end := p.i + 1
+	for ; end < len(p.s); end++ {
+		if p.s[end] == '\n' {
+			break
+		}
+		if q == '"' && p.s[end] == '\\' {
+			end++
+			continue
+		}
+		if p.s[end] == q {
+			raw := p.s[p.i : end+1]
+			p.i = end + 1
+			if q == '\'' {
+				return raw[1 : len(raw)-1], nil
+			}
+			v, err := strconv.Unquote(raw)
+			if err != nil {
+				return "", fmt.Errorf("invalid string %s", raw)
+			}
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+
+	for _, tc := range []struct {
+		in   string
+		want map[string]interface{}
+	}{
+		{``, map[string]interface{}{}},
+		{"# only a comment\n\n", map[string]interface{}{}},
+		{`package = "example.com/lib"`, map[string]interface{}{"package": "example.com/lib"}},
+		{"a = 'C:\\no\\escapes'\nb = \"tab\\there \\\"q\\\" \\u00e9\"", map[string]interface{}{
+			"a": `C:\no\escapes`,
+			"b": "tab\there \"q\" é",
+		}},
+		{`a = "has # no comment" # a comment`, map[string]interface{}{"a": "has # no comment"}},
+		{"\"quoted key\" = 1\n'lit-key' = -2\nbare_key-2 = +3_000", map[string]interface{}{
+			"quoted key": int64(1),
+			"lit-key":    int64(-2),
+			"bare_key-2": int64(3000),
+		}},
+		{"t = true\nf = false", map[string]interface{}{"t": true, "f": false}},
+		{`requires = []`, map[string]interface{}{"requires": []interface{}{}}},
+		{`sri = ["sha256", 'sha384']`, map[string]interface{}{"sri": []interface{}{"sha256", "sha384"}}},
+		{"include = [\n  \"*.js\", # scripts\n  \"*.css\",\n]\n", map[string]interface{}{"include": []interface{}{"*.js", "*.css"}}},
+		{"nested = [[1], []]", map[string]interface{}{"nested": []interface{}{[]interface{}{int64(1)}, []interface{}{}}}},
+		{"package = \"x\"\n\n[metadata] # table\nlicense = \"MIT\"\n\"the name\" = 'x'\n", map[string]interface{}{
+			"package":  "x",
+			"metadata": map[string]interface{}{"license": "MIT", "the name": "x"},
+		}},
+		{"\r\na = 1\r\n", map[string]interface{}{"a": int64(1)}},
+	} {
+		got, err := parseTOML([]byte(tc.in))
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %#v, want %#v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+
+	for _, tc := range []struct {
+		in      string
+		wantErr string
+	}{
+		{`a = "unterminated`, "line 1: value of \"a\": unterminated string"},
+		{"a = \"multi\nline\"", "line 1: value of \"a\": unterminated string"},
+		{`a = "bad \q escape"`, "invalid string"},
+		{"\n\na", "line 3: expected = after key \"a\""},
+		{"a = ", "missing value"},
+		{"a = 1 b = 2", "line 1: unexpected \"b\", expected end of line"},
+		{"a = 1\na = 2", "line 2: \"a\" is defined more than once"},
+		{"[t]\n[t]", "line 2: \"t\" is defined more than once"},
+		{"[t", "expected ] after table name \"t\""},
+		{"= 1", "expected a key"},
+		{"a = [1 2]", "expected , or ] in array"},
+		{"a = 1.5", "unexpected \".\""},
+		{"a = 99999999999999999999", "unsupported number"},
+		{"a = 2023-01-01", "unexpected \"-\""},
+		{"a = {b = 1}", "unsupported value starting with \"{\""},
+		{"a = [\n1,\n\"x]", "line 3: value of \"a\": unterminated string"},
+	} {
+		_, err := parseTOML([]byte(tc.in))
+		if err == nil {
+			t.Errorf("%q: expected error containing %q", tc.in, tc.wantErr)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%q: error %q does not contain %q", tc.in, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package webresource
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Preload is a resource the browser should start fetching early.
+type Preload struct {
+	URL         string
+	As          string // "style", "script", "font", etc.
+	Type        string // optional MIME type, e.g. "font/woff2"
+	CrossOrigin bool   // add crossorigin, required by browsers for fonts and for files tagged with integrity
+	Integrity   string // optional subresource integrity value, e.g. "sha384-..."
+}
+
+// PreloadListOptions configures AssetPreloads and ModulePreloads.
+type PreloadListOptions struct {
+	// Integrity sets Integrity and CrossOrigin on style and script preloads, use it when
+	// the tags for them are emitted with TemplateOptions.Integrity, otherwise the browser
+	// does not reuse the preloaded response and fetches each file twice.
+	Integrity    bool
+	IntegrityAlg string // hash algorithm for Integrity, DefaultIntegrityAlg if empty
+}
+
+func (opts PreloadListOptions) alg() string {
+	if opts.IntegrityAlg == "" {
+		return DefaultIntegrityAlg
+	}
+	return opts.IntegrityAlg
+}
+
+// wantIntegrity is true if p should get an integrity value with opts
+func (opts PreloadListOptions) wantIntegrity(p Preload) bool {
+	return opts.Integrity && (p.As == "style" || p.As == "script")
+}
+
+// LinkHeader returns the value for a Link header, e.g. `</a.css>; rel=preload; as=style`.
+func (p Preload) LinkHeader() string {
+	v := "<" + p.URL + ">; rel=preload; as=" + p.As
+	if p.Type != "" {
+		v += `; type="` + p.Type + `"`
+	}
+	if p.CrossOrigin {
+		v += "; crossorigin"
+	}
+	if p.Integrity != "" {
+		v += `; integrity="` + p.Integrity + `"`
+	}
+	return v
+}
+
+// PreloadFor returns a Preload for URL u with As set from the extension of its path,
+// an error is returned for extensions that are not known.
+func PreloadFor(u string) (Preload, error) {
+	p := u
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	switch ext := path.Ext(p); ext {
+	case ".css":
+		return Preload{URL: u, As: "style"}, nil
+	case ".js", ".mjs":
+		return Preload{URL: u, As: "script"}, nil
+	}
+	if IsFont(p) {
+		return Preload{URL: u, As: "font", Type: ContentType(p), CrossOrigin: true}, nil
+	}
+	return Preload{}, fmt.Errorf("no preload type known for %q", u)
+}
+
+// AssetPreloads returns a Preload for each of the named Assets, e.g. the bundles for a page.
+func AssetPreloads(assets *Assets, opts PreloadListOptions, names ...string) ([]Preload, error) {
+	ret := make([]Preload, 0, len(names))
+	for _, name := range names {
+		// one lookup, URL and integrity must be of the same version of the asset
+		asset := assets.Named(name)
+		if asset == nil {
+			return nil, fmt.Errorf("no asset named %q", name)
+		}
+		p, err := PreloadFor(asset.URL)
+		if err != nil {
+			return nil, err
+		}
+		if opts.wantIntegrity(p) {
+			p.Integrity, err = asset.Integrity(opts.alg())
+			if err != nil {
+				return nil, err
+			}
+			p.CrossOrigin = true
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// ModulePreloads returns a Preload for each file with one of the extensions given in ml,
+// with URLs from h, e.g. when serving one file per tag during development.
+func ModulePreloads(ml ModuleList, h *ModuleHandler, opts PreloadListOptions, exts ...string) ([]Preload, error) {
+	var ret []Preload
+	for _, ext := range exts {
+		err := ml.Walk(ext, func(m Module, fullPath string, f http.File) error {
+			u, err := h.URL(m, fullPath)
+			if err != nil {
+				return err
+			}
+			p, err := PreloadFor(u)
+			if err != nil {
+				return err
+			}
+			if opts.wantIntegrity(p) {
+				p.Integrity, err = FileIntegrity(m, fullPath, opts.alg())
+				if err != nil {
+					return err
+				}
+				p.CrossOrigin = true
+			}
+			ret = append(ret, p)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Tag returns the Preload as a <link rel="preload"> tag.
+func (p Preload) Tag() template.HTML {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<link rel="preload" href="%s" as="%s"`, template.HTMLEscapeString(p.URL), template.HTMLEscapeString(p.As))
+	if p.Type != "" {
+		fmt.Fprintf(&buf, ` type="%s"`, template.HTMLEscapeString(p.Type))
+	}
+	if p.CrossOrigin {
+		fmt.Fprintf(&buf, ` crossorigin`)
+	}
+	if p.Integrity != "" {
+		fmt.Fprintf(&buf, ` integrity="%s"`, template.HTMLEscapeString(p.Integrity))
+	}
+	fmt.Fprintf(&buf, ">\n")
+	return template.HTML(buf.String())
+}
+
+// PreloadOptions configures NewPreloader.
+type PreloadOptions struct {
+	Preloads     []Preload                       // sent for every request
+	PreloadsFunc func(r *http.Request) []Preload // optional, additional preloads per request
+	EarlyHints   bool                            // also send a 103 Early Hints response, where supported
+}
+
+// NewPreloader returns a Preloader with the options given.
+func NewPreloader(opts PreloadOptions) *Preloader {
+	return &Preloader{opts: opts}
+}
+
+// Preloader tells the browser about resources before the page itself is rendered.
+type Preloader struct {
+	opts PreloadOptions
+}
+
+// Middleware adds a Link header for each Preload to the response.  With EarlyHints
+// enabled and a Go version which supports informational responses (1.19+) the same
+// headers are first sent in a 103 Early Hints response, before next is called.
+func (p *Preloader) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		preloads := p.opts.Preloads
+		if p.opts.PreloadsFunc != nil {
+			preloads = append(preloads[:len(preloads):len(preloads)], p.opts.PreloadsFunc(r)...)
+		}
+		for _, pl := range preloads {
+			w.Header().Add("Link", pl.LinkHeader())
+		}
+		if p.opts.EarlyHints && len(preloads) > 0 && r.Method == "GET" {
+			writeEarlyHints(w)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
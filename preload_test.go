@@ -0,0 +1,97 @@
+package webresource
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreload(t *testing.T) {
+
+	assets := NewAssets("/assets/")
+	css := assets.Add("combined.css", time.Now(), []byte(`/* css */`))
+	js := assets.Add("combined.js", time.Now(), []byte(`/* js */`))
+
+	preloads, err := AssetPreloads(assets, PreloadListOptions{}, "combined.css", "combined.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pl := NewPreloader(PreloadOptions{
+		Preloads: preloads,
+		PreloadsFunc: func(r *http.Request) []Preload {
+			return []Preload{{URL: "/fonts/a.woff2", As: "font", Type: "font/woff2", CrossOrigin: true}}
+		},
+	})
+	h := pl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	expected := []string{
+		"<" + css.URL + ">; rel=preload; as=style",
+		"<" + js.URL + ">; rel=preload; as=script",
+		`</fonts/a.woff2>; rel=preload; as=font; type="font/woff2"; crossorigin`,
+	}
+	if !reflect.DeepEqual(w.Header()["Link"], expected) {
+		t.Fatalf("unexpected Link headers: %v", w.Header()["Link"])
+	}
+
+	// with integrity the preloads match the tags TemplateOptions.Integrity emits
+	preloads, err = AssetPreloads(assets, PreloadListOptions{Integrity: true, IntegrityAlg: "sha256"}, "combined.css", "combined.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cssIntegrity, err := css.Integrity("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !preloads[0].CrossOrigin || preloads[0].Integrity != cssIntegrity || !strings.HasPrefix(preloads[1].Integrity, "sha256-") {
+		t.Fatalf("unexpected preloads with integrity: %+v", preloads)
+	}
+	if h := preloads[0].LinkHeader(); h != "<"+css.URL+`>; rel=preload; as=style; crossorigin; integrity="`+cssIntegrity+`"` {
+		t.Fatalf("unexpected Link header: %s", h)
+	}
+	tags := TemplateFuncs(TemplateOptions{Assets: assets, StyleBundle: "combined.css", Integrity: true, IntegrityAlg: "sha256"})
+	tag, err := tags["wrStyles"].(func(...interface{}) (template.HTML, error))()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(tag), `integrity="`+cssIntegrity+`" crossorigin="anonymous"`) {
+		t.Fatalf("tag does not match preload: %s", tag)
+	}
+	if pt := preloads[0].Tag(); pt != template.HTML(`<link rel="preload" href="`+css.URL+`" as="style" crossorigin integrity="`+cssIntegrity+`">`+"\n") {
+		t.Fatalf("unexpected preload tag: %s", pt)
+	}
+
+	if _, err := AssetPreloads(assets, PreloadListOptions{}, "missing.js"); err == nil {
+		t.Fatalf("expected error for missing asset")
+	}
+
+	// fonts are not loaded with integrity, so their preloads get none
+	fs := NewFileSet("example.com/a").
+		WriteFile("/a.js", 0644, time.Now(), []byte(`/* js */`)).
+		WriteFile("/a.woff2", 0644, time.Now(), []byte(`font`))
+	preloads, err = ModulePreloads(ModuleList{fs}, Handler(ModuleList{fs}, HandlerOptions{}), PreloadListOptions{Integrity: true}, ".js", ".woff2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsIntegrity, err := FileIntegrity(fs, "/a.js", DefaultIntegrityAlg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preloads) != 2 || preloads[0].Integrity != jsIntegrity || !preloads[0].CrossOrigin || preloads[1].Integrity != "" || !preloads[1].CrossOrigin {
+		t.Fatalf("unexpected module preloads: %+v", preloads)
+	}
+
+	if _, err := PreloadFor("/a.txt"); err == nil {
+		t.Fatalf("expected error for unknown extension")
+	}
+
+}
@@ -0,0 +1,193 @@
+package webresource
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// TemplateOptions configures the functions returned by TemplateFuncs.
+type TemplateOptions struct {
+	Modules ModuleList     // Resolve()d modules, used for one tag per file when there is no bundle
+	Handler *ModuleHandler // serves the module files, provides their URLs when there is no bundle
+
+	Assets       *Assets // fingerprinted assets containing the bundles
+	StyleBundle  string  // logical name in Assets of the CSS bundle, e.g. "combined.css", empty for one tag per file
+	ScriptBundle string  // logical name in Assets of the JS bundle, e.g. "combined.js", empty for one tag per file
+
+	Integrity    bool   // add integrity (and crossorigin) attributes, see also PreloadListOptions.Integrity
+	IntegrityAlg string // hash algorithm for integrity attributes, DefaultIntegrityAlg if empty
+	Defer        bool   // add defer to script tags
+	Async        bool   // add async to script tags
+	Module       bool   // use type="module" for script tags
+
+	FontPreloadExt string // the one font format wrFontPreloads preloads, ".woff2" if empty
+}
+
+// TemplateFuncs returns template functions which emit the tags for the CSS and JS of
+// the modules, either one tag per bundle or one per file:
+//
+//	{{wrStyles}}  - <link rel="stylesheet"> tags
+//	{{wrScripts}} - <script> tags
+//	{{wrImportMap}} - <script type="importmap"> for the modules' ES module specifiers, see ESModuler
+//	{{wrFontPreloads}} - <link rel="preload" as="font" crossorigin> tags for the modules' font files in
+//	one format only, FontPreloadExt, since browsers download every format that is preloaded
+//
+// wrStyles, wrScripts and wrImportMap accept an optional nonce argument which is added as a nonce
+// attribute, e.g. {{wrScripts .Nonce}}.  The argument may also be a context.Context or *http.Request,
+// in which case NonceFromContext is used.  wrFontPreloads takes no arguments.
+func TemplateFuncs(opts TemplateOptions) template.FuncMap {
+	return template.FuncMap{
+		"wrStyles": func(args ...interface{}) (template.HTML, error) {
+			return opts.tags(".css", opts.StyleBundle, args)
+		},
+		"wrScripts": func(args ...interface{}) (template.HTML, error) {
+			return opts.tags(".js", opts.ScriptBundle, args)
+		},
+		"wrImportMap": func(args ...interface{}) (template.HTML, error) {
+			nonce, err := templateNonce(args)
+			if err != nil {
+				return "", err
+			}
+			if opts.Handler == nil {
+				return "", fmt.Errorf("no Handler provided to generate import map URLs")
+			}
+			im, err := NewImportMap(opts.Modules, opts.Handler)
+			if err != nil {
+				return "", err
+			}
+			return im.ScriptTag(nonce)
+		},
+		"wrFontPreloads": func() (template.HTML, error) {
+			if opts.Handler == nil {
+				return "", fmt.Errorf("no Handler provided to generate font URLs")
+			}
+			ext := opts.FontPreloadExt
+			if ext == "" {
+				ext = FontExts[0]
+			}
+			preloads, err := ModulePreloads(opts.Modules, opts.Handler, PreloadListOptions{}, ext)
+			if err != nil {
+				return "", err
+			}
+			var ret template.HTML
+			for _, p := range preloads {
+				ret += p.Tag()
+			}
+			return ret, nil
+		},
+	}
+}
+
+// tagSource is the URL and integrity value of one file a tag is emitted for
+type tagSource struct {
+	url       string
+	integrity string
+}
+
+func (opts TemplateOptions) tags(ext, bundleName string, args []interface{}) (template.HTML, error) {
+
+	nonce, err := templateNonce(args)
+	if err != nil {
+		return "", err
+	}
+
+	alg := opts.IntegrityAlg
+	if alg == "" {
+		alg = DefaultIntegrityAlg
+	}
+
+	var srcs []tagSource
+
+	if bundleName != "" {
+		if opts.Assets == nil {
+			return "", fmt.Errorf("bundle %q specified but no Assets provided", bundleName)
+		}
+		asset := opts.Assets.Named(bundleName)
+		if asset == nil {
+			return "", fmt.Errorf("no asset named %q", bundleName)
+		}
+		src := tagSource{url: asset.URL}
+		if opts.Integrity {
+			src.integrity, err = asset.Integrity(alg)
+			if err != nil {
+				return "", err
+			}
+		}
+		srcs = append(srcs, src)
+	} else {
+		if opts.Handler == nil {
+			return "", fmt.Errorf("no Handler provided to generate %q file URLs", ext)
+		}
+		err := opts.Modules.Walk(ext, func(m Module, fullPath string, f http.File) error {
+			u, err := opts.Handler.URL(m, fullPath)
+			if err != nil {
+				return err
+			}
+			src := tagSource{url: u}
+			if opts.Integrity {
+				src.integrity, err = FileIntegrity(m, fullPath, alg)
+				if err != nil {
+					return err
+				}
+			}
+			srcs = append(srcs, src)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, src := range srcs {
+		if ext == ".css" {
+			fmt.Fprintf(&buf, `<link rel="stylesheet" href="%s"`, template.HTMLEscapeString(src.url))
+		} else {
+			fmt.Fprintf(&buf, `<script src="%s"`, template.HTMLEscapeString(src.url))
+			if opts.Module {
+				fmt.Fprintf(&buf, ` type="module"`)
+			}
+			if opts.Defer {
+				fmt.Fprintf(&buf, ` defer`)
+			}
+			if opts.Async {
+				fmt.Fprintf(&buf, ` async`)
+			}
+		}
+		if opts.Integrity {
+			fmt.Fprintf(&buf, ` integrity="%s" crossorigin="anonymous"`, src.integrity)
+		}
+		if nonce != "" {
+			fmt.Fprintf(&buf, ` nonce="%s"`, template.HTMLEscapeString(nonce))
+		}
+		if ext == ".css" {
+			fmt.Fprintf(&buf, ">\n")
+		} else {
+			fmt.Fprintf(&buf, "></script>\n")
+		}
+	}
+
+	return template.HTML(buf.String()), nil
+}
+
+// templateNonce extracts the nonce from the arguments to a template function
+func templateNonce(args []interface{}) (string, error) {
+	switch len(args) {
+	case 0:
+		return "", nil
+	case 1:
+		switch v := args[0].(type) {
+		case string:
+			return v, nil
+		case context.Context:
+			return NonceFromContext(v), nil
+		case *http.Request:
+			return NonceFromContext(v.Context()), nil
+		}
+		return "", fmt.Errorf("unsupported nonce argument type %T", args[0])
+	}
+	return "", fmt.Errorf("expected at most one argument, got %d", len(args))
+}
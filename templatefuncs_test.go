@@ -0,0 +1,59 @@
+package webresource
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+
+	modTime := time.Unix(1500000000, 0)
+
+	a := NewFileSet("example.com/a").
+		WriteFile("/a.css", 0644, modTime, []byte(`/* a.css */`)).
+		WriteFile("/a.js", 0644, modTime, []byte(`/* a.js */`))
+	b := NewFileSet("example.com/b", a).
+		WriteFile("/b.js", 0644, modTime, []byte(`/* b.js */`))
+	ml := Resolve(ModuleList{b})
+
+	render := func(opts TemplateOptions, text string, data interface{}) string {
+		tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(opts)).Parse(text))
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	// dev mode, one tag per file
+	h := Handler(ml, HandlerOptions{})
+	out := render(TemplateOptions{Modules: ml, Handler: h, Defer: true}, `{{wrStyles}}{{wrScripts .}}`, "abc123")
+	expected := `<link rel="stylesheet" href="/_wr/example.com/a/a.css">
+<script src="/_wr/example.com/a/a.js" defer nonce="abc123"></script>
+<script src="/_wr/example.com/b/b.js" defer nonce="abc123"></script>
+`
+	if out != expected {
+		t.Fatalf("unexpected output:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	// bundled mode with integrity
+	assets := NewAssets("/assets/")
+	js := assets.Add("combined.js", modTime, []byte(`/* combined */`))
+	out = render(TemplateOptions{Assets: assets, ScriptBundle: "combined.js", Integrity: true, Module: true}, `{{wrScripts}}`, nil)
+	jsIntegrity, _ := Integrity("sha384", js.Content)
+	expected = `<script src="` + js.URL + `" type="module" integrity="` + jsIntegrity + `" crossorigin="anonymous"></script>
+`
+	if out != expected {
+		t.Fatalf("unexpected output:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	// missing bundle is an error
+	tmpl := template.Must(template.New("t").Funcs(TemplateFuncs(TemplateOptions{Assets: assets, StyleBundle: "combined.css"})).Parse(`{{wrStyles}}`))
+	if err := tmpl.Execute(&bytes.Buffer{}, nil); err == nil {
+		t.Fatalf("expected error for missing bundle")
+	}
+
+}
@@ -0,0 +1,175 @@
+package webresource
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// WalkContext works like Walk() but checks ctx before visiting each file and
+// stops with ctx.Err() once it is done.
+func (l ModuleList) WalkContext(ctx context.Context, ext string, fn WalkFunc) error {
+	for _, m := range l {
+		err := WalkContext(ctx, m, ext, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkContext works like Walk() but checks ctx before visiting each file and
+// stops with ctx.Err() once it is done.
+func WalkContext(ctx context.Context, m Module, ext string, fn WalkFunc) error {
+	return walkPaths(m, ext, func(fullPath string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return walkFile(m, fullPath, fn)
+	})
+}
+
+// ProcessFunc is called concurrently by WalkParallel for each file and returns
+// a result which is later handed to a ResultFunc.
+type ProcessFunc func(ctx context.Context, m Module, fullPath string, f http.File) (interface{}, error)
+
+// ResultFunc receives the result of a ProcessFunc call.  It is called from a single
+// goroutine in the same sequence Walk() would visit the files.
+type ResultFunc func(m Module, fullPath string, result interface{}) error
+
+// WalkParallel visits the same files as Walk() but calls process from up to workers
+// goroutines at once (workers < 1 means 1).  Results are passed to result in Walk()
+// sequence, so output built from a Resolve()d list stays in dependency order.
+// The first error (in sequence) or the cancellation of ctx stops the walk and is returned.
+func (l ModuleList) WalkParallel(ctx context.Context, ext string, workers int, process ProcessFunc, result ResultFunc) error {
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// gather the full sequence up front so results can be matched back to it
+	type job struct {
+		m        Module
+		fullPath string
+		result   interface{}
+		err      error
+		done     chan struct{}
+	}
+	var jobs []*job
+	for _, m := range l {
+		m := m
+		err := walkPaths(m, ext, func(fullPath string) error {
+			jobs = append(jobs, &job{m: m, fullPath: fullPath, done: make(chan struct{})})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobCh := make(chan *job)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				if err := ctx.Err(); err != nil {
+					j.err = err
+				} else {
+					j.err = walkFile(j.m, j.fullPath, func(m Module, fullPath string, f http.File) error {
+						var err error
+						j.result, err = process(ctx, m, fullPath, f)
+						return err
+					})
+				}
+				close(j.done)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobCh)
+		for _, j := range jobs {
+			select {
+			case jobCh <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var err error
+	for _, j := range jobs {
+		select {
+		case <-j.done:
+		case <-ctx.Done():
+		}
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if err = j.err; err != nil {
+			break
+		}
+		if err = result(j.m, j.fullPath, j.result); err != nil {
+			break
+		}
+	}
+
+	cancel()
+	wg.Wait()
+
+	return err
+}
+
+// ResolvedWalkFunc is called by WalkResolved for each file.  Index is the position of m
+// in the Resolve()d list and importers is the chain of modules that pulled m in, starting
+// with one of the roots and ending with the module that requires m directly (empty for roots).
+type ResolvedWalkFunc func(m Module, index int, importers []Module, fullPath string, f http.File) error
+
+// WalkResolved calls Resolve() on roots and walks the result, i.e. unlike Walk() it
+// follows Requires() transitively.  Sequence is the same as Resolve(roots).Walk().
+func WalkResolved(roots ModuleList, ext string, fn ResolvedWalkFunc) error {
+
+	chains := make(map[string][]Module)
+	importerChains(roots, nil, chains)
+
+	for i, m := range Resolve(roots) {
+		i, importers := i, chains[m.Name()]
+		err := Walk(m, ext, func(m Module, fullPath string, f http.File) error {
+			return fn(m, i, importers, fullPath, f)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importerChains follows the same sequence as Resolve() and records in chains the
+// importer chain through which each module is first reached.
+func importerChains(r ModuleList, chain []Module, chains map[string][]Module) {
+
+	r2 := make(ModuleList, len(r))
+	copy(r2, r)
+	sort.Sort(r2)
+
+	for _, m := range r2 {
+		if _, ok := chains[m.Name()]; ok {
+			continue
+		}
+		// copy so sibling calls cannot clobber each other's chain
+		mchain := make([]Module, len(chain), len(chain)+1)
+		copy(mchain, chain)
+		importerChains(requireModules(m.Requires()), append(mchain, m), chains)
+		if _, ok := chains[m.Name()]; !ok {
+			chains[m.Name()] = mchain
+		}
+	}
+
+}
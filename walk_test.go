@@ -0,0 +1,135 @@
+package webresource
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func walkTestModules() ModuleList {
+	a := NewFileSet("a").WriteFile("/a.js", 0644, time.Now(), []byte(`/* a.js */`))
+	b := NewFileSet("b", a).WriteFile("/b.js", 0644, time.Now(), []byte(`/* b.js */`))
+	c := NewFileSet("c", a).WriteFile("/c.js", 0644, time.Now(), []byte(`/* c.js */`))
+	d := NewFileSet("d", b, c).
+		Mkdir("/subdir", 0755).
+		WriteFile("/subdir/d1.js", 0644, time.Now(), []byte(`/* d1.js */`)).
+		WriteFile("/subdir/d2.js", 0644, time.Now(), []byte(`/* d2.js */`))
+	return Resolve(ModuleList{d})
+}
+
+func TestWalkContext(t *testing.T) {
+
+	mlr := walkTestModules()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := 0
+	err := mlr.WalkContext(ctx, ".js", func(m Module, fullPath string, f http.File) error {
+		n++
+		if n == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected walk to stop after 2 files, visited %d", n)
+	}
+
+}
+
+func TestWalkParallel(t *testing.T) {
+
+	mlr := walkTestModules()
+
+	// make earlier files finish last
+	delays := map[string]time.Duration{
+		"/a.js":         20 * time.Millisecond,
+		"/b.js":         15 * time.Millisecond,
+		"/c.js":         10 * time.Millisecond,
+		"/subdir/d1.js": 5 * time.Millisecond,
+	}
+
+	{
+		var buf bytes.Buffer
+		err := mlr.WalkParallel(context.Background(), ".js", 3,
+			func(ctx context.Context, m Module, fullPath string, f http.File) (interface{}, error) {
+				b, err := ioutil.ReadAll(f)
+				if err != nil {
+					return nil, err
+				}
+				time.Sleep(delays[fullPath])
+				return b, nil
+			},
+			func(m Module, fullPath string, result interface{}) error {
+				fmt.Fprintf(&buf, "%s", result)
+				return nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != `/* a.js *//* b.js *//* c.js *//* d1.js *//* d2.js */` {
+			t.Fatalf("unexpected result: %s", buf.String())
+		}
+	}
+
+	{
+		var buf bytes.Buffer
+		err := mlr.WalkParallel(context.Background(), ".js", 2,
+			func(ctx context.Context, m Module, fullPath string, f http.File) (interface{}, error) {
+				if fullPath == "/c.js" {
+					return nil, fmt.Errorf("failed on %s", fullPath)
+				}
+				return fullPath, nil
+			},
+			func(m Module, fullPath string, result interface{}) error {
+				fmt.Fprintf(&buf, "%s;", result)
+				return nil
+			})
+		if err == nil || err.Error() != "failed on /c.js" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buf.String() != `/a.js;/b.js;` {
+			t.Fatalf("unexpected result: %s", buf.String())
+		}
+	}
+
+}
+
+func TestWalkResolved(t *testing.T) {
+
+	a := NewFileSet("a").WriteFile("/a.js", 0644, time.Now(), []byte(`/* a.js */`))
+	b := NewFileSet("b", a).WriteFile("/b.js", 0644, time.Now(), []byte(`/* b.js */`))
+	c := NewFileSet("c", a).WriteFile("/c.js", 0644, time.Now(), []byte(`/* c.js */`))
+	d := NewFileSet("d", b, c).WriteFile("/d.js", 0644, time.Now(), []byte(`/* d.js */`))
+
+	var buf bytes.Buffer
+	err := WalkResolved(ModuleList{d}, ".js", func(m Module, index int, importers []Module, fullPath string, f http.File) error {
+		fmt.Fprintf(&buf, "%d %s", index, fullPath)
+		for i := len(importers) - 1; i >= 0; i-- {
+			fmt.Fprintf(&buf, " <- %s", importers[i].Name())
+		}
+		fmt.Fprintf(&buf, "\n")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `0 /a.js <- b <- d
+1 /b.js <- d
+2 /c.js <- d
+3 /d.js
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected result:\n%s", buf.String())
+	}
+
+}
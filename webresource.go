@@ -20,6 +20,20 @@ type Module interface {
 	Requires() []interface{}
 }
 
+// Manifester is an optional interface a Module can implement to declare an explicit
+// sequence for its files.  Manifest returns full paths (e.g. "/core.js") in the order
+// they should be visited.  Walk visits the files listed here first, in order, followed
+// by any remaining files in Readdir() sequence.
+type Manifester interface {
+	Manifest() []string
+}
+
+// MetadataProvider is an optional interface a Module can implement to describe itself,
+// e.g. {"version": "4.7.0", "license": "MIT"}.  Keys are free form, by convention lower case.
+type MetadataProvider interface {
+	Metadata() map[string]string
+}
+
 func requireModules(ilist []interface{}) ModuleList {
 	ret := make(ModuleList, 0, len(ilist))
 	for _, i := range ilist {
@@ -96,7 +110,7 @@ func (p ModuleList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 type WalkFunc func(m Module, fullPath string, f http.File) error
 
 // Walk will visit each file in each FileSystem contained in this Module by calling the fn function.
-// This does not walk Requires().  Sequence is determined by the underlying Readdir() calls.
+// This does not walk Requires().  Sequence within each Module is the same as for Walk().
 func (l ModuleList) Walk(ext string, fn WalkFunc) error {
 	for _, m := range l {
 		err := Walk(m, ext, fn)
@@ -108,12 +122,40 @@ func (l ModuleList) Walk(ext string, fn WalkFunc) error {
 }
 
 // Walk will visit each file in the FileSystem contained in this Module by calling the fn function.
-// This does not walk Requires().  Sequence is determined by the underlying Readdir() calls.
+// This does not walk Requires().  If the Module implements Manifester the files it lists are
+// visited first in that sequence, otherwise sequence is determined by the underlying Readdir() calls.
 func Walk(m Module, ext string, fn WalkFunc) error {
-	return walkDir(m, "/", ext, fn)
+	return walkPaths(m, ext, func(fullPath string) error {
+		return walkFile(m, fullPath, fn)
+	})
 }
 
-func walkDir(m Module, root string, ext string, fn WalkFunc) error {
+// walkPaths calls visit with the full path of each file that Walk would visit, in the same sequence
+func walkPaths(m Module, ext string, visit func(fullPath string) error) error {
+
+	visited := make(map[string]bool)
+
+	if mf, ok := m.(Manifester); ok {
+		for _, p := range mf.Manifest() {
+			fullPath := path.Clean("/" + p)
+			if visited[fullPath] {
+				continue
+			}
+			visited[fullPath] = true
+			if path.Ext(fullPath) != ext {
+				continue
+			}
+			err := visit(fullPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return walkDir(m, "/", ext, visited, visit)
+}
+
+func walkDir(m Module, root string, ext string, visited map[string]bool, visit func(fullPath string) error) error {
 
 	dirf, err := m.Open(root)
 	if err != nil {
@@ -132,7 +174,7 @@ func walkDir(m Module, root string, ext string, fn WalkFunc) error {
 		if fi.IsDir() {
 
 			newRoot := path.Join(root, fi.Name())
-			err := walkDir(m, newRoot, ext, fn)
+			err := walkDir(m, newRoot, ext, visited, visit)
 			if err != nil {
 				return err
 			}
@@ -148,16 +190,13 @@ func walkDir(m Module, root string, ext string, fn WalkFunc) error {
 			continue
 		}
 
-		// open and close each file right here
-		err := func() error {
-			fullPath := path.Join(root, fi.Name())
-			f, err := m.Open(fullPath)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			return fn(m, fullPath, f)
-		}()
+		// skip files already visited from the manifest
+		fullPath := path.Join(root, base)
+		if visited[fullPath] {
+			continue
+		}
+
+		err := visit(fullPath)
 		if err != nil {
 			return err
 		}
@@ -166,3 +205,13 @@ func walkDir(m Module, root string, ext string, fn WalkFunc) error {
 
 	return nil
 }
+
+// walkFile opens and closes a single file around the call to fn
+func walkFile(m Module, fullPath string, fn WalkFunc) error {
+	f, err := m.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fn(m, fullPath, f)
+}
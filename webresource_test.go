@@ -94,3 +94,34 @@ func TestWalk(t *testing.T) {
 	}
 
 }
+
+func TestWalkManifest(t *testing.T) {
+
+	a := NewFileSet("a").
+		WriteFile("/plugin-a.js", 0644, time.Now(), []byte(`/* plugin-a.js */`)).
+		WriteFile("/other.js", 0644, time.Now(), []byte(`/* other.js */`)).
+		Mkdir("/lib", 0755).
+		WriteFile("/lib/core.js", 0644, time.Now(), []byte(`/* core.js */`)).
+		SetManifest("/lib/core.js", "/plugin-a.js")
+
+	var buf bytes.Buffer
+	err := Walk(a, ".js", func(m Module, fullPath string, f http.File) error {
+		fmt.Fprintf(&buf, "%s;", fullPath)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != `/lib/core.js;/plugin-a.js;/other.js;` {
+		t.Fatalf("unexpected result: %s", buf.String())
+	}
+
+	// manifest entries that do not exist are an error
+	a.SetManifest("/missing.js")
+	err = Walk(a, ".js", func(m Module, fullPath string, f http.File) error { return nil })
+	if err == nil {
+		t.Fatalf("expected error for missing manifest entry")
+	}
+
+}